@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"strconv"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 type GetFeeRateResponse struct {
@@ -17,6 +19,15 @@ type GetFeeRateResponse struct {
 	} `json:"fees"`
 }
 
+// fees are given in basis points
+func (r *GetFeeRateResponse) TakerRate() decimal.Decimal {
+	return decimal.NewFromInt(int64(r.Fees.Taker)).Div(bpsFactor)
+}
+
+func (r *GetFeeRateResponse) MakerRate() decimal.Decimal {
+	return decimal.NewFromInt(int64(r.Fees.Maker)).Div(bpsFactor)
+}
+
 func (p *Client) GetFeeRate(token string) (result *GetFeeRateResponse, err error) {
 	nonce := time.Now().Unix()
 	s, err := p.sign(strconv.FormatInt(nonce, 10))
@@ -192,7 +203,6 @@ func (p *Client) Register() (result *RegisterResponse, err error) {
 	params["starkKey"] = publ
 	params["nonce"] = nonceStr
 	params["signature"] = s
-	fmt.Println(publ)
 	jsonBody, err := json.Marshal(params)
 	if err != nil {
 		return nil, err
@@ -203,8 +213,6 @@ func (p *Client) Register() (result *RegisterResponse, err error) {
 		return nil, err
 	}
 
-	b, _ := ioutil.ReadAll(res.Body)
-	fmt.Println(string(b))
 	err = decode(res, &result)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,106 @@
+package dvfapi
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// requests of a batch in flight at the same time, the client rate limiter still applies
+const batchWorkers = 10
+
+type SubmitOrdersResult struct {
+	Request  OrderRequest
+	Response *SubmitOrderResponse
+	Err      error
+}
+
+type CancelOrdersResult struct {
+	OrderId  string
+	Response *CancelOrderResponse
+	Err      error
+}
+
+// SubmitOrders sends the orders concurrently, results keep the order of the requests.
+func (p *Client) SubmitOrders(orders []OrderRequest) []SubmitOrdersResult {
+	results := make([]SubmitOrdersResult, len(orders))
+	fanOut(len(orders), func(i int) {
+		order := orders[i]
+		res, err := p.SubmitOrder(&order)
+		results[i] = SubmitOrdersResult{Request: order, Response: res, Err: err}
+	})
+	return results
+}
+
+// SubmitOrdersAllOrNothing sends the orders like SubmitOrders, if any of them fails the live ones are canceled.
+// Orders already closed, filled or IOC, cannot be rolled back, the returned error lists them next to what failed.
+// The results keep the placements and the cancels are in the second slice.
+func (p *Client) SubmitOrdersAllOrNothing(orders []OrderRequest) ([]SubmitOrdersResult, []CancelOrdersResult, error) {
+	results := p.SubmitOrders(orders)
+	var failed int
+	var firstErr error
+	var live, closed []string
+	for _, res := range results {
+		if res.Err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = res.Err
+			}
+		}
+		// an order can be live and still fail, like an IOC whose remainder was not canceled
+		switch {
+		case res.Response == nil:
+		case res.Response.Active || res.Response.Pending:
+			live = append(live, res.Response.ID)
+		default:
+			closed = append(closed, res.Response.ID)
+		}
+	}
+	if failed == 0 {
+		return results, nil, nil
+	}
+	var notRolledBack string
+	if len(closed) != 0 {
+		notRolledBack = fmt.Sprintf(", already closed %s", strings.Join(closed, ","))
+	}
+	cancels := p.CancelOrders(live)
+	for _, c := range cancels {
+		if c.Err != nil {
+			return results, cancels, fmt.Errorf("%d of %d orders failed (%v) and order %s could not be rolled back%s: %w", failed, len(orders), firstErr, c.OrderId, notRolledBack, c.Err)
+		}
+	}
+	return results, cancels, fmt.Errorf("%d of %d orders failed, live orders canceled%s: %w", failed, len(orders), notRolledBack, firstErr)
+}
+
+// CancelOrders cancels the orders concurrently, results keep the order of the ids.
+func (p *Client) CancelOrders(orderIds []string) []CancelOrdersResult {
+	results := make([]CancelOrdersResult, len(orderIds))
+	fanOut(len(orderIds), func(i int) {
+		res, err := p.CancelOrder(orderIds[i])
+		results[i] = CancelOrdersResult{OrderId: orderIds[i], Response: res, Err: err}
+	})
+	return results
+}
+
+func fanOut(n int, job func(i int)) {
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	workers := batchWorkers
+	if n < workers {
+		workers = n
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				job(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,258 @@
+package dvfapi
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type BookEventType int
+
+const (
+	// a snapshot has been applied, the book is ready
+	SnapshotApplied BookEventType = iota
+	// a price level was added, resized or removed (zero Qty)
+	LevelChanged
+	// best bid or best ask moved
+	TopOfBookChanged
+	// the book was cleared for a resync, on close C is closed instead
+	BookReset
+)
+
+func (t BookEventType) String() string {
+	switch t {
+	case SnapshotApplied:
+		return "snapshot applied"
+	case LevelChanged:
+		return "level changed"
+	case TopOfBookChanged:
+		return "top of book changed"
+	case BookReset:
+		return "book reset"
+	}
+	return "unknown"
+}
+
+type BookEvent struct {
+	Type BookEventType
+	Time time.Time
+	// LevelChanged
+	Bid   bool
+	Price decimal.Decimal
+	Qty   decimal.Decimal
+	// TopOfBookChanged and SnapshotApplied, zero when the side is empty
+	BestBid decimal.Decimal
+	BestAsk decimal.Decimal
+}
+
+// what to do with an event when the buffer of a subscriber is full
+type DropPolicy int
+
+const (
+	// drop the incoming event
+	DropNewest DropPolicy = iota
+	// drop the oldest buffered event to make room
+	DropOldest
+	// wait for the subscriber, this holds the book updates back
+	Block
+)
+
+type BookSubscription struct {
+	C       <-chan BookEvent
+	ch      chan BookEvent
+	policy  DropPolicy
+	dropped uint64
+	hub     *bookHub
+	once    sync.Once
+	// closed on unsubscribe, releases a blocked delivery
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// events dropped because the subscriber was behind
+func (s *BookSubscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Unsubscribe stops the deliveries and closes C.
+func (s *BookSubscription) Unsubscribe() {
+	s.once.Do(func() {
+		s.release()
+		s.hub.remove(s)
+	})
+}
+
+func (s *BookSubscription) release() {
+	s.doneOnce.Do(func() { close(s.done) })
+}
+
+func (s *BookSubscription) deliver(ev BookEvent) {
+	switch s.policy {
+	case Block:
+		select {
+		case s.ch <- ev:
+		case <-s.done:
+		}
+	case DropOldest:
+		for {
+			select {
+			case s.ch <- ev:
+				return
+			default:
+			}
+			select {
+			case <-s.ch:
+				atomic.AddUint64(&s.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.ch <- ev:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+type bookHub struct {
+	mux   sync.RWMutex
+	subs  map[*BookSubscription]struct{}
+	count int32
+	// set by close, nothing is published or subscribed after it
+	closed int32
+	// last published top of book
+	topMux  sync.Mutex
+	bestBid decimal.Decimal
+	bestAsk decimal.Decimal
+}
+
+func (h *bookHub) add(s *BookSubscription) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if atomic.LoadInt32(&h.closed) != 0 {
+		s.once.Do(func() {
+			s.release()
+			close(s.ch)
+		})
+		return
+	}
+	if h.subs == nil {
+		h.subs = make(map[*BookSubscription]struct{})
+	}
+	h.subs[s] = struct{}{}
+	atomic.AddInt32(&h.count, 1)
+}
+
+func (h *bookHub) remove(s *BookSubscription) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	delete(h.subs, s)
+	atomic.AddInt32(&h.count, -1)
+	close(s.ch)
+}
+
+func (h *bookHub) active() bool {
+	return atomic.LoadInt32(&h.count) != 0
+}
+
+// close unsubscribes everyone, releasing deliveries blocked on a subscriber that stopped reading
+func (h *bookHub) close() {
+	atomic.StoreInt32(&h.closed, 1)
+	// a blocked publish holds the read lock, the subscriptions are released before remove takes the lock
+	h.mux.RLock()
+	subs := make([]*BookSubscription, 0, len(h.subs))
+	for s := range h.subs {
+		subs = append(subs, s)
+	}
+	h.mux.RUnlock()
+	// release all before removing any, a publish blocked on one subscriber holds up the removal of the others
+	for _, s := range subs {
+		s.release()
+	}
+	for _, s := range subs {
+		s.Unsubscribe()
+	}
+}
+
+func (h *bookHub) publish(ev BookEvent) {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	if atomic.LoadInt32(&h.closed) != 0 {
+		return
+	}
+	for s := range h.subs {
+		s.deliver(ev)
+	}
+}
+
+// records the new top of book, returns false if it did not move
+func (h *bookHub) setTop(bestBid, bestAsk decimal.Decimal) bool {
+	h.topMux.Lock()
+	defer h.topMux.Unlock()
+	if h.bestBid.Equal(bestBid) && h.bestAsk.Equal(bestAsk) {
+		return false
+	}
+	h.bestBid = bestBid
+	h.bestAsk = bestAsk
+	return true
+}
+
+// Subscribe to the book events, buffer is the number of events kept for the subscriber.
+// C is closed by Unsubscribe and by Close of the book.
+func (o *OrderBookBranch) Subscribe(buffer int, policy DropPolicy) *BookSubscription {
+	ch := make(chan BookEvent, buffer)
+	s := &BookSubscription{C: ch, ch: ch, policy: policy, hub: &o.events, done: make(chan struct{})}
+	o.events.add(s)
+	return s
+}
+
+// OnEvent calls fn for every book event from its own goroutine, until the subscription is closed.
+func (o *OrderBookBranch) OnEvent(fn func(BookEvent), buffer int, policy DropPolicy) *BookSubscription {
+	s := o.Subscribe(buffer, policy)
+	o.goes(func() {
+		for ev := range s.C {
+			fn(ev)
+		}
+	})
+	return s
+}
+
+func (o *OrderBookBranch) bestPrices() (decimal.Decimal, decimal.Decimal) {
+	bestBid, _, bestAsk, _, _ := o.top.get()
+	return bestBid, bestAsk
+}
+
+func (o *OrderBookBranch) publishLevel(bid bool, price, qty decimal.Decimal) {
+	if !o.events.active() || !o.SnapShoted {
+		return
+	}
+	now := time.Now()
+	o.events.publish(BookEvent{Type: LevelChanged, Time: now, Bid: bid, Price: price, Qty: qty})
+	bestBid, bestAsk := o.bestPrices()
+	if o.events.setTop(bestBid, bestAsk) {
+		o.events.publish(BookEvent{Type: TopOfBookChanged, Time: now, BestBid: bestBid, BestAsk: bestAsk})
+	}
+}
+
+func (o *OrderBookBranch) publishSnapshot() {
+	if !o.events.active() {
+		return
+	}
+	bestBid, bestAsk := o.bestPrices()
+	o.events.setTop(bestBid, bestAsk)
+	o.events.publish(BookEvent{Type: SnapshotApplied, Time: time.Now(), BestBid: bestBid, BestAsk: bestAsk})
+}
+
+func (o *OrderBookBranch) publishReset() {
+	o.events.setTop(decimal.Zero, decimal.Zero)
+	if !o.events.active() {
+		return
+	}
+	o.events.publish(BookEvent{Type: BookReset, Time: time.Now()})
+}
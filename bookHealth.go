@@ -0,0 +1,237 @@
+package dvfapi
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type BookState int
+
+const (
+	// dialing the socket
+	Connecting BookState = iota
+	// connected, waiting for the first snapshot
+	Syncing
+	// snapshot applied and updates flowing
+	Live
+	// no message for longer than the staleness threshold
+	Stale
+	// waiting for a new snapshot after a refresh or a reconnect
+	Resyncing
+	Closed
+)
+
+func (s BookState) String() string {
+	switch s {
+	case Connecting:
+		return "connecting"
+	case Syncing:
+		return "syncing"
+	case Live:
+		return "live"
+	case Stale:
+		return "stale"
+	case Resyncing:
+		return "resyncing"
+	case Closed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// used when BookOptions.StaleAfter is not set, heartbeats come every 15 seconds
+const defaultStaleAfter = 20 * time.Second
+
+type BookStatus struct {
+	State BookState
+	// when the book entered the state
+	Since      time.Time
+	LastUpdate time.Time
+	// messages per second, smoothed
+	UpdateRate float64
+	Reconnects int
+	Latency    LatencyStats
+}
+
+type bookHealth struct {
+	mux        sync.RWMutex
+	state      BookState
+	since      time.Time
+	rate       float64
+	reconnects int
+	staleAfter time.Duration
+	// messages since the last rate sample
+	messages uint64
+	// times the book went live, tells if a connection worked
+	lives   uint64
+	changes chan BookStatus
+}
+
+func (h *bookHealth) init(staleAfter time.Duration) {
+	if staleAfter <= 0 {
+		staleAfter = defaultStaleAfter
+	}
+	h.staleAfter = staleAfter
+	h.state = Connecting
+	h.since = time.Now()
+	h.changes = make(chan BookStatus, 16)
+}
+
+func (o *OrderBookBranch) Status() BookStatus {
+	o.health.mux.RLock()
+	defer o.health.mux.RUnlock()
+	return o.statusLocked()
+}
+
+// caller holds health mux
+func (o *OrderBookBranch) statusLocked() BookStatus {
+	return BookStatus{
+		State:      o.health.state,
+		Since:      o.health.since,
+		LastUpdate: o.LastUpdate(),
+		UpdateRate: o.health.rate,
+		Reconnects: o.health.reconnects,
+		Latency:    o.UpdateLatency(),
+	}
+}
+
+// StatusChanges delivers the status on every state change, the oldest are dropped if nobody reads them.
+func (o *OrderBookBranch) StatusChanges() <-chan BookStatus {
+	return o.health.changes
+}
+
+func (o *OrderBookBranch) setState(state BookState) {
+	o.health.mux.Lock()
+	defer o.health.mux.Unlock()
+	if o.health.state == state || o.health.state == Closed {
+		return
+	}
+	o.health.state = state
+	o.health.since = time.Now()
+	if state == Live {
+		atomic.AddUint64(&o.health.lives, 1)
+	}
+	status := o.statusLocked()
+	for {
+		select {
+		case o.health.changes <- status:
+			return
+		default:
+		}
+		select {
+		case <-o.health.changes:
+		default:
+		}
+	}
+}
+
+func (o *OrderBookBranch) liveCount() uint64 {
+	return atomic.LoadUint64(&o.health.lives)
+}
+
+func (o *OrderBookBranch) state() BookState {
+	o.health.mux.RLock()
+	defer o.health.mux.RUnlock()
+	return o.health.state
+}
+
+// a new connection is being made for the book
+func (o *OrderBookBranch) connecting() {
+	o.health.mux.Lock()
+	first := o.health.state == Connecting
+	if !first {
+		o.health.reconnects++
+	}
+	o.health.mux.Unlock()
+	if !first {
+		o.setState(Resyncing)
+	}
+}
+
+// a message arrived from the feed
+func (o *OrderBookBranch) received() {
+	o.touch()
+	atomic.AddUint64(&o.health.messages, 1)
+	switch o.state() {
+	case Connecting:
+		o.setState(Syncing)
+	case Stale:
+		if o.SnapShoted {
+			o.setState(Live)
+		} else {
+			o.setState(Resyncing)
+		}
+	}
+}
+
+// samples the update rate and flags the book stale when the feed goes quiet
+func (o *OrderBookBranch) monitorHealth(ctx context.Context) {
+	interval := o.health.staleAfter / 4
+	if interval > time.Second {
+		interval = time.Second
+	}
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			count := atomic.SwapUint64(&o.health.messages, 0)
+			sample := float64(count) / now.Sub(last).Seconds()
+			last = now
+			o.health.mux.Lock()
+			o.health.rate = 0.8*o.health.rate + 0.2*sample
+			o.health.mux.Unlock()
+			switch o.state() {
+			case Live, Syncing, Resyncing:
+				if updated := o.LastUpdate(); !updated.IsZero() && now.Sub(updated) > o.health.staleAfter {
+					o.setState(Stale)
+				}
+			}
+		}
+	}
+}
+
+// LatencyStats measure the time from a frame coming off the socket to the book being mutated by it.
+type LatencyStats struct {
+	Last    time.Duration
+	Avg     time.Duration
+	Max     time.Duration
+	Samples uint64
+}
+
+type latencyStats struct {
+	mux   sync.Mutex
+	stats LatencyStats
+	avg   float64
+}
+
+func (l *latencyStats) observe(d time.Duration) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.stats.Samples++
+	l.stats.Last = d
+	if d > l.stats.Max {
+		l.stats.Max = d
+	}
+	if l.stats.Samples == 1 {
+		l.avg = float64(d)
+	} else {
+		l.avg = 0.95*l.avg + 0.05*float64(d)
+	}
+	l.stats.Avg = time.Duration(l.avg)
+}
+
+// UpdateLatency of the book updates, Avg is smoothed over the last updates.
+func (o *OrderBookBranch) UpdateLatency() LatencyStats {
+	o.latency.mux.Lock()
+	defer o.latency.mux.Unlock()
+	return o.latency.stats
+}
@@ -0,0 +1,338 @@
+package dvfapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+// BookManager keeps the local order books of many symbols over a single websocket.
+// Frames are routed to the books by channel id, every subscription is sent again after a reconnect.
+type BookManager struct {
+	url    string
+	logger *log.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+	retry  *backoff
+
+	mux      sync.Mutex
+	books    map[string]*managedBook
+	channels map[float64]*managedBook
+
+	connMux  sync.Mutex
+	conn     *websocket.Conn
+	writeMux sync.Mutex
+}
+
+type managedBook struct {
+	symbol string
+	opt    BookOptions
+	branch *OrderBookBranch
+	// channel id and snapshot state of the subscription
+	socket *DVFWebsocket
+	feed   chan *BookMessage
+	// set while messages of the book are dropped because its feed is full
+	lagging int32
+	cancel  context.CancelFunc
+}
+
+func NewBookManager(logger *log.Logger) *BookManager {
+	return NewBookManagerWithPolicy(logger, DefaultReconnectPolicy())
+}
+
+func NewBookManagerWithPolicy(logger *log.Logger, policy ReconnectPolicy) *BookManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &BookManager{
+		url:      SocketEndPointHub(false),
+		logger:   logger,
+		ctx:      ctx,
+		cancel:   cancel,
+		retry:    newBackoff(&policy),
+		books:    make(map[string]*managedBook),
+		channels: make(map[float64]*managedBook),
+	}
+	go keepConnected(ctx, "book manager", m.retry, logger, func(context.Context) error {
+		return m.run()
+	})
+	return m
+}
+
+// Add subscribes the symbol and returns its local order book, example: ETH:USDT
+func (m *BookManager) Add(symbol string, opt BookOptions) (*OrderBookBranch, error) {
+	if err := opt.Validate(); err != nil {
+		return nil, err
+	}
+	symbol = strings.ToUpper(symbol)
+	m.mux.Lock()
+	if _, ok := m.books[symbol]; ok {
+		m.mux.Unlock()
+		return nil, fmt.Errorf("%s is already subscribed", symbol)
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	b := &managedBook{
+		symbol: symbol,
+		opt:    opt,
+		branch: newOrderBookBranch(opt),
+		socket: &DVFWebsocket{Channel: "orderbook", Logger: m.logger},
+		feed:   make(chan *BookMessage, 50),
+		cancel: cancel,
+	}
+	b.branch.Cancel = &cancel
+	m.books[symbol] = b
+	m.mux.Unlock()
+
+	b.branch.goes(func() { m.maintain(ctx, b) })
+	b.branch.goes(func() { b.branch.monitorHealth(ctx) })
+	if err := m.subscribe(b); err != nil {
+		// the subscription goes out with the next connection
+		m.logger.Warningf("DVF book manager fail to subscribe %s: %s", symbol, err.Error())
+	}
+	return b.branch, nil
+}
+
+// Remove unsubscribes the symbol and stops its local order book.
+func (m *BookManager) Remove(symbol string) error {
+	symbol = strings.ToUpper(symbol)
+	m.mux.Lock()
+	b, ok := m.books[symbol]
+	if !ok {
+		m.mux.Unlock()
+		return fmt.Errorf("%s is not subscribed", symbol)
+	}
+	delete(m.books, symbol)
+	id := b.socket.ChannelID
+	delete(m.channels, id)
+	m.mux.Unlock()
+	err := b.branch.Close()
+	if id != 0 {
+		if err := m.write(GetDVFUnsubscribeMessage(id)); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (m *BookManager) Book(symbol string) (*OrderBookBranch, bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	b, ok := m.books[strings.ToUpper(symbol)]
+	if !ok {
+		return nil, false
+	}
+	return b.branch, true
+}
+
+func (m *BookManager) Symbols() []string {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	symbols := make([]string, 0, len(m.books))
+	for symbol := range m.books {
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+// Close stops every book and the connection, returns the first book that did not stop.
+func (m *BookManager) Close() error {
+	m.cancel()
+	m.mux.Lock()
+	books := m.books
+	m.books = make(map[string]*managedBook)
+	m.channels = make(map[float64]*managedBook)
+	m.mux.Unlock()
+	var err error
+	for _, b := range books {
+		if closeErr := b.branch.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("%s: %w", b.symbol, closeErr)
+		}
+	}
+	m.connMux.Lock()
+	if m.conn != nil {
+		m.conn.Close()
+	}
+	m.connMux.Unlock()
+	return err
+}
+
+// keeps the book of one symbol, asks the server for a fresh snapshot when the book wants a refresh
+func (m *BookManager) maintain(ctx context.Context, b *managedBook) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			err := b.branch.MaintainOrderBook(ctx, b.symbol, &b.feed)
+			if err == nil {
+				return
+			}
+			m.logger.Warningf("refreshing %s local orderbook cause: %s", b.symbol, err.Error())
+			b.branch.setState(Resyncing)
+			if err := m.resubscribe(b); err != nil {
+				m.logger.Warningf("DVF book manager fail to resubscribe %s: %s", b.symbol, err.Error())
+			}
+		}
+	}
+}
+
+func (m *BookManager) write(message []byte) error {
+	m.connMux.Lock()
+	conn := m.conn
+	m.connMux.Unlock()
+	if conn == nil {
+		return errors.New("not connected")
+	}
+	m.writeMux.Lock()
+	defer m.writeMux.Unlock()
+	return conn.WriteMessage(websocket.TextMessage, message)
+}
+
+func (m *BookManager) subscribe(b *managedBook) error {
+	return m.write(GetDVFBookSubscribeMessage(b.symbol, b.opt))
+}
+
+func (m *BookManager) resubscribe(b *managedBook) error {
+	m.mux.Lock()
+	id := b.socket.ChannelID
+	delete(m.channels, id)
+	b.socket.ChannelID = 0
+	m.mux.Unlock()
+	if id != 0 {
+		if err := m.write(GetDVFUnsubscribeMessage(id)); err != nil {
+			return err
+		}
+	}
+	return m.subscribe(b)
+}
+
+// subscribes every book on a new connection and routes their frames until it breaks
+func (m *BookManager) run() error {
+	conn, _, err := websocket.DefaultDialer.Dial(m.url, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	m.connMux.Lock()
+	m.conn = conn
+	m.connMux.Unlock()
+	defer func() {
+		m.connMux.Lock()
+		m.conn = nil
+		m.connMux.Unlock()
+	}()
+	m.logger.Infoln("DVF book manager socket connected.")
+
+	if err := m.write(GetDVFConfMessage(DVFChecksumFlag)); err != nil {
+		return err
+	}
+	m.mux.Lock()
+	m.channels = make(map[float64]*managedBook)
+	books := make([]*managedBook, 0, len(m.books))
+	for _, b := range m.books {
+		b.socket.ChannelID = 0
+		b.branch.connecting()
+		books = append(books, b)
+	}
+	m.mux.Unlock()
+	for _, b := range books {
+		if err := m.subscribe(b); err != nil {
+			return err
+		}
+	}
+
+	control := DVFWebsocket{Logger: m.logger}
+	connected := false
+	frames, stop := readFrames(conn)
+	defer stop()
+	ping := time.NewTicker(socketPingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return nil
+		case <-ping.C:
+			if err := sendPing(conn); err != nil {
+				return err
+			}
+		case frame, ok := <-frames:
+			if !ok {
+				return errors.New("socket reader stopped")
+			}
+			if frame.err != nil {
+				return frame.err
+			}
+			if !connected {
+				// the server answers, this is a working connection
+				connected = true
+				m.retry.reset()
+			}
+			res, err := DecodeDVFFrame(frame.data)
+			if err != nil {
+				return err
+			}
+			if err := m.route(res, &control, frame.received); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (m *BookManager) route(frame *DVFFrame, control *DVFWebsocket, received time.Time) error {
+	if event := frame.Event; event != nil {
+		switch event.Event {
+		case "subscribed":
+			m.mux.Lock()
+			b, ok := m.books[strings.ToUpper(event.Symbol)]
+			if ok {
+				m.channels[event.ChanID] = b
+				b.socket.HandleDVFEvent(event)
+			}
+			m.mux.Unlock()
+			if !ok {
+				// removed while subscribing
+				return m.write(GetDVFUnsubscribeMessage(event.ChanID))
+			}
+			return nil
+		case "error":
+			if event.Symbol != "" {
+				// a failed subscription only concerns its symbol
+				m.logger.Warningf("DVF book manager subscription of %s failed: %s", event.Symbol, event.Msg)
+				return nil
+			}
+		}
+		return control.HandleDVFEvent(event)
+	}
+	m.mux.Lock()
+	b, ok := m.channels[frame.ChanID]
+	m.mux.Unlock()
+	if !ok {
+		// unsubscribed channel still flushing
+		return nil
+	}
+	// the channel map is the only place the book and its channel id are matched
+	message, err := frameMessage(frame, received, m.logger)
+	if err != nil {
+		b.branch.RefreshLocalOrderBook(err)
+		return nil
+	}
+	if message == nil {
+		return nil
+	}
+	// one slow book must not hold up the socket of the others
+	select {
+	case b.feed <- message:
+		atomic.StoreInt32(&b.lagging, 0)
+	default:
+		if atomic.CompareAndSwapInt32(&b.lagging, 0, 1) {
+			m.logger.Warningf("DVF book manager %s feed is full, dropping messages until it resyncs", b.symbol)
+		}
+		b.branch.RefreshLocalOrderBook(errors.New("feed full, messages dropped"))
+	}
+	return nil
+}
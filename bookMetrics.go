@@ -0,0 +1,158 @@
+package dvfapi
+
+import (
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+var bpsFactor = decimal.NewFromInt(10000)
+
+// best level of each side, kept up to date on every level change
+type topOfBook struct {
+	mux      sync.RWMutex
+	bidPrice decimal.Decimal
+	bidQty   decimal.Decimal
+	hasBid   bool
+	askPrice decimal.Decimal
+	askQty   decimal.Decimal
+	hasAsk   bool
+}
+
+// caller holds the side mux
+func (t *topOfBook) update(bid bool, best *priceLevel) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if bid {
+		t.hasBid = best != nil
+		t.bidPrice, t.bidQty = decimal.Zero, decimal.Zero
+		if best != nil {
+			t.bidPrice, t.bidQty = best.price, best.qty
+		}
+		return
+	}
+	t.hasAsk = best != nil
+	t.askPrice, t.askQty = decimal.Zero, decimal.Zero
+	if best != nil {
+		t.askPrice, t.askQty = best.price, best.qty
+	}
+}
+
+func (t *topOfBook) get() (bidPrice, bidQty, askPrice, askQty decimal.Decimal, ok bool) {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	return t.bidPrice, t.bidQty, t.askPrice, t.askQty, t.hasBid && t.hasAsk
+}
+
+// return best bid price and size, ready or not
+func (o *OrderBookBranch) BestBid() (decimal.Decimal, decimal.Decimal, bool) {
+	o.top.mux.RLock()
+	defer o.top.mux.RUnlock()
+	return o.top.bidPrice, o.top.bidQty, o.top.hasBid && o.SnapShoted
+}
+
+// return best ask price and size, ready or not
+func (o *OrderBookBranch) BestAsk() (decimal.Decimal, decimal.Decimal, bool) {
+	o.top.mux.RLock()
+	defer o.top.mux.RUnlock()
+	return o.top.askPrice, o.top.askQty, o.top.hasAsk && o.SnapShoted
+}
+
+func (o *OrderBookBranch) Mid() (decimal.Decimal, bool) {
+	bid, _, ask, _, ok := o.top.get()
+	if !ok || !o.SnapShoted {
+		return decimal.Zero, false
+	}
+	return bid.Add(ask).Div(decimal.NewFromInt(2)), true
+}
+
+// best ask minus best bid
+func (o *OrderBookBranch) Spread() (decimal.Decimal, bool) {
+	bid, _, ask, _, ok := o.top.get()
+	if !ok || !o.SnapShoted {
+		return decimal.Zero, false
+	}
+	return ask.Sub(bid), true
+}
+
+// spread over mid, in basis points
+func (o *OrderBookBranch) SpreadBps() (decimal.Decimal, bool) {
+	bid, _, ask, _, ok := o.top.get()
+	if !ok || !o.SnapShoted {
+		return decimal.Zero, false
+	}
+	mid := bid.Add(ask).Div(decimal.NewFromInt(2))
+	if mid.IsZero() {
+		return decimal.Zero, false
+	}
+	return ask.Sub(bid).Div(mid).Mul(bpsFactor), true
+}
+
+// mid weighted by the size on the opposite side: (bid * askQty + ask * bidQty) / (bidQty + askQty)
+func (o *OrderBookBranch) Microprice() (decimal.Decimal, bool) {
+	bid, bidQty, ask, askQty, ok := o.top.get()
+	total := bidQty.Add(askQty)
+	if !ok || !o.SnapShoted || total.IsZero() {
+		return decimal.Zero, false
+	}
+	return bid.Mul(askQty).Add(ask.Mul(bidQty)).Div(total), true
+}
+
+// imbalance of the best levels, (bidQty - askQty) / (bidQty + askQty), from -1 to 1
+func (o *OrderBookBranch) Imbalance() (decimal.Decimal, bool) {
+	_, bidQty, _, askQty, ok := o.top.get()
+	total := bidQty.Add(askQty)
+	if !ok || !o.SnapShoted || total.IsZero() {
+		return decimal.Zero, false
+	}
+	return bidQty.Sub(askQty).Div(total), true
+}
+
+type DepthWithin struct {
+	Bps         decimal.Decimal
+	BidQty      decimal.Decimal
+	BidNotional decimal.Decimal
+	BidLevels   int
+	AskQty      decimal.Decimal
+	AskNotional decimal.Decimal
+	AskLevels   int
+	// (BidQty - AskQty) / (BidQty + AskQty)
+	Imbalance decimal.Decimal
+}
+
+// DepthWithinBps sums the size resting within bps of mid on each side.
+func (o *OrderBookBranch) DepthWithinBps(bps decimal.Decimal) (DepthWithin, bool) {
+	depth := DepthWithin{Bps: bps}
+	mid, ok := o.Mid()
+	if !ok {
+		return depth, false
+	}
+	band := mid.Mul(bps).Div(bpsFactor)
+	floor, ceil := mid.Sub(band), mid.Add(band)
+	o.Bids.mux.RLock()
+	o.Bids.levels.each(func(price, qty decimal.Decimal) bool {
+		if price.LessThan(floor) {
+			return false
+		}
+		depth.BidQty = depth.BidQty.Add(qty)
+		depth.BidNotional = depth.BidNotional.Add(price.Mul(qty))
+		depth.BidLevels++
+		return true
+	})
+	o.Bids.mux.RUnlock()
+	o.Asks.mux.RLock()
+	o.Asks.levels.each(func(price, qty decimal.Decimal) bool {
+		if price.GreaterThan(ceil) {
+			return false
+		}
+		depth.AskQty = depth.AskQty.Add(qty)
+		depth.AskNotional = depth.AskNotional.Add(price.Mul(qty))
+		depth.AskLevels++
+		return true
+	})
+	o.Asks.mux.RUnlock()
+	if total := depth.BidQty.Add(depth.AskQty); !total.IsZero() {
+		depth.Imbalance = depth.BidQty.Sub(depth.AskQty).Div(total)
+	}
+	return depth, true
+}
@@ -0,0 +1,55 @@
+package dvfapi
+
+import (
+	"fmt"
+	"time"
+)
+
+// BookOptions are the parameters of the book subscription, empty fields leave the server default.
+type BookOptions struct {
+	// P0 to P4 for books aggregated by price with decreasing precision, R0 for the raw book
+	Precision string
+	// F0 sends every update in real time, F1 batches them every 2 seconds
+	Frequency string
+	// number of price levels per side, 25 or 100
+	Length int
+	// how long the feed may stay silent before the book is flagged stale, 20 seconds if not set
+	StaleAfter time.Duration
+	// nil uses DefaultReconnectPolicy
+	Reconnect *ReconnectPolicy
+	// every raw frame of the socket is written to it when set
+	Recorder *FrameRecorder
+}
+
+func (b BookOptions) Validate() error {
+	switch b.Precision {
+	case "", "P0", "P1", "P2", "P3", "P4", "R0":
+	default:
+		return fmt.Errorf("unknown book precision %s, want P0 to P4 or R0", b.Precision)
+	}
+	switch b.Frequency {
+	case "", "F0", "F1":
+	default:
+		return fmt.Errorf("unknown book frequency %s, want F0 or F1", b.Frequency)
+	}
+	switch b.Length {
+	case 0, 25, 100:
+	default:
+		return fmt.Errorf("unsupported book length %d, want 25 or 100", b.Length)
+	}
+	if b.StaleAfter < 0 {
+		return fmt.Errorf("negative stale threshold %s", b.StaleAfter)
+	}
+	if b.StaleAfter > 0 && b.StaleAfter < time.Millisecond {
+		// most likely seconds given as a bare number
+		return fmt.Errorf("stale threshold %s is below a millisecond", b.StaleAfter)
+	}
+	return nil
+}
+
+func (b BookOptions) lengthString() string {
+	if b.Length == 0 {
+		return ""
+	}
+	return fmt.Sprint(b.Length)
+}
@@ -0,0 +1,75 @@
+package dvfapi
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type PriceLevel struct {
+	Price decimal.Decimal
+	Qty   decimal.Decimal
+}
+
+// BookSnapshot is a copy of both sides taken at the same point of the feed.
+type BookSnapshot struct {
+	Bids []PriceLevel
+	Asks []PriceLevel
+	// number of level changes applied to the book when the snapshot was taken
+	Seq uint64
+	// time of the last message of the feed
+	Time time.Time
+}
+
+// Snapshot copies both sides under their locks, so the view is never half updated. Returns false if the book is not ready.
+func (o *OrderBookBranch) Snapshot() (BookSnapshot, bool) {
+	var snap BookSnapshot
+	o.Bids.mux.RLock()
+	defer o.Bids.mux.RUnlock()
+	o.Asks.mux.RLock()
+	defer o.Asks.mux.RUnlock()
+	if !o.SnapShoted {
+		return snap, false
+	}
+	snap.Seq = atomic.LoadUint64(&o.seq)
+	snap.Time = o.LastUpdate()
+	snap.Bids = make([]PriceLevel, 0, o.Bids.len())
+	o.Bids.levels.each(func(price, qty decimal.Decimal) bool {
+		snap.Bids = append(snap.Bids, PriceLevel{Price: price, Qty: qty})
+		return true
+	})
+	snap.Asks = make([]PriceLevel, 0, o.Asks.len())
+	o.Asks.levels.each(func(price, qty decimal.Decimal) bool {
+		snap.Asks = append(snap.Asks, PriceLevel{Price: price, Qty: qty})
+		return true
+	})
+	return snap, true
+}
+
+// Seq is the number of level changes applied since the book was created.
+func (o *OrderBookBranch) Seq() uint64 {
+	return atomic.LoadUint64(&o.seq)
+}
+
+// Crossed tells if the best bid is at or above the best ask, which only happens when the local book is out of sync.
+func (o *OrderBookBranch) Crossed() bool {
+	bid, _, ask, _, ok := o.top.get()
+	return ok && bid.GreaterThanOrEqual(ask)
+}
+
+// refresh the book if an update left it crossed
+func (o *OrderBookBranch) checkCrossed() {
+	if o.SnapShoted && o.Crossed() {
+		o.RefreshLocalOrderBook(errors.New("crossed book"))
+	}
+}
+
+func copyBook(book [][]string) [][]string {
+	out := make([][]string, len(book))
+	for i, level := range book {
+		out[i] = append([]string(nil), level...)
+	}
+	return out
+}
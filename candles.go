@@ -0,0 +1,176 @@
+package dvfapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Candle is an OHLCV bar, Time is the start of the bar
+type Candle struct {
+	Time   time.Time
+	Open   float64
+	Close  float64
+	High   float64
+	Low    float64
+	Volume float64
+}
+
+// candle rows are [mts, open, close, high, low, volume]
+func (c *Candle) UnmarshalJSON(data []byte) error {
+	var row []float64
+	if err := json.Unmarshal(data, &row); err != nil {
+		return fmt.Errorf("bad candle: %w", err)
+	}
+	if len(row) != 6 {
+		return fmt.Errorf("candle with %d fields, want 6", len(row))
+	}
+	c.Time = time.UnixMilli(int64(row[0]))
+	c.Open, c.Close, c.High, c.Low, c.Volume = row[1], row[2], row[3], row[4], row[5]
+	return nil
+}
+
+// CandleKey builds the key of a candle stream, example: CandleKey("1m", "ETH:USDT") is trade:1m:ETH:USDT
+func CandleKey(timeframe, symbol string) string {
+	return "trade:" + timeframe + ":" + strings.ToUpper(symbol)
+}
+
+// CandleSeries is a time ordered series of bars without duplicates.
+type CandleSeries struct {
+	mux  sync.RWMutex
+	bars []Candle
+}
+
+// Merge a live bar, it replaces the bar with the same time.
+func (s *CandleSeries) Merge(c Candle) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.insert(c, true)
+}
+
+// MergeHistory adds historical bars, the bars already in the series are live and kept.
+func (s *CandleSeries) MergeHistory(bars []Candle) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for _, c := range bars {
+		s.insert(c, false)
+	}
+}
+
+// caller holds mux
+func (s *CandleSeries) insert(c Candle, replace bool) {
+	i := sort.Search(len(s.bars), func(i int) bool {
+		return !s.bars[i].Time.Before(c.Time)
+	})
+	if i < len(s.bars) && s.bars[i].Time.Equal(c.Time) {
+		if replace {
+			s.bars[i] = c
+		}
+		return
+	}
+	s.bars = append(s.bars, Candle{})
+	copy(s.bars[i+1:], s.bars[i:])
+	s.bars[i] = c
+}
+
+// Bars returns a copy of the series, oldest first.
+func (s *CandleSeries) Bars() []Candle {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return append([]Candle(nil), s.bars...)
+}
+
+func (s *CandleSeries) Last() (Candle, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	if len(s.bars) == 0 {
+		return Candle{}, false
+	}
+	return s.bars[len(s.bars)-1], true
+}
+
+// CandleBranch follows a candles channel into a series.
+type CandleBranch struct {
+	key       string
+	cancel    context.CancelFunc
+	Series    *CandleSeries
+	mux       sync.RWMutex
+	callbacks []func(Candle)
+}
+
+// LocalCandles subscribes the candles of the key, example: trade:1m:ETH:USDT
+func LocalCandles(key string, logger *log.Logger) *CandleBranch {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &CandleBranch{
+		key:    key,
+		cancel: cancel,
+		Series: &CandleSeries{},
+	}
+	go runChannelSocket(ctx, key+" candles", GetDVFCandlesSubscribeMessage(key), newBackoff(nil), logger, c.handleFrame)
+	return c
+}
+
+// LiveCandles subscribes the candles of the key and backfills the series from the REST history since start,
+// so the series runs continuously from start to the live bar.
+func (p *Client) LiveCandles(key string, start time.Time, logger *log.Logger) (*CandleBranch, error) {
+	c := LocalCandles(key, logger)
+	history, err := p.GetCandles(key, start, time.Now())
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.Series.MergeHistory(history)
+	return c, nil
+}
+
+// OnCandle calls fn on every live bar update, from the socket goroutine.
+func (c *CandleBranch) OnCandle(fn func(Candle)) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.callbacks = append(c.callbacks, fn)
+}
+
+func (c *CandleBranch) Close() {
+	c.cancel()
+}
+
+func (c *CandleBranch) handleFrame(frame *DVFFrame, received time.Time) error {
+	if frame.Type != "" {
+		return nil
+	}
+	if frame.Snapshot {
+		var bars []Candle
+		if err := json.Unmarshal(frame.Payload, &bars); err != nil {
+			return err
+		}
+		for _, bar := range bars {
+			c.Series.Merge(bar)
+		}
+		return nil
+	}
+	var bar Candle
+	if err := json.Unmarshal(frame.Payload, &bar); err != nil {
+		return err
+	}
+	c.Series.Merge(bar)
+	c.mux.RLock()
+	callbacks := c.callbacks
+	c.mux.RUnlock()
+	for _, fn := range callbacks {
+		fn(bar)
+	}
+	return nil
+}
+
+func GetDVFCandlesSubscribeMessage(key string) []byte {
+	by, err := json.Marshal(DVFSubscribeMessage{Event: "subscribe", Channel: "candles", Key: key})
+	if err != nil {
+		return nil
+	}
+	return by
+}
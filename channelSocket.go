@@ -0,0 +1,90 @@
+package dvfapi
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+// runChannelSocket keeps a single channel subscription on its own socket, reconnecting with backoff.
+// handle gets the data frames of the channel, heartbeats and events are dealt with here.
+func runChannelSocket(
+	ctx context.Context,
+	name string,
+	subscribe []byte,
+	retry *backoff,
+	logger *log.Logger,
+	handle func(frame *DVFFrame, received time.Time) error,
+) {
+	keepConnected(ctx, name, retry, logger, func(ctx context.Context) error {
+		return channelSocket(ctx, name, subscribe, retry, logger, handle)
+	})
+}
+
+// subscribes on a new connection and feeds handle until the connection breaks
+func channelSocket(
+	ctx context.Context,
+	name string,
+	subscribe []byte,
+	retry *backoff,
+	logger *log.Logger,
+	handle func(frame *DVFFrame, received time.Time) error,
+) error {
+	w := DVFWebsocket{Logger: logger}
+	conn, _, err := websocket.DefaultDialer.Dial(SocketEndPointHub(false), nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	logger.Infof("DVF %s socket connected.", name)
+	w.Conn = conn
+	if err := conn.WriteMessage(websocket.TextMessage, subscribe); err != nil {
+		return err
+	}
+	frames, stop := readFrames(conn)
+	defer stop()
+	ping := time.NewTicker(socketPingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ping.C:
+			if err := sendPing(conn); err != nil {
+				return err
+			}
+		case frame, ok := <-frames:
+			if !ok {
+				return errors.New("socket reader stopped")
+			}
+			if frame.err != nil {
+				return frame.err
+			}
+			res, err := DecodeDVFFrame(frame.data)
+			if err != nil {
+				return err
+			}
+			if res.Event != nil {
+				if err := w.HandleDVFEvent(res.Event); err != nil {
+					return err
+				}
+				if res.Event.Event == "subscribed" {
+					retry.reset()
+				}
+				continue
+			}
+			if res.ChanID != w.ChannelID {
+				return errors.New("wrong channel id return")
+			}
+			if res.Type == "hb" {
+				continue
+			}
+			if err := handle(res, frame.received); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"reflect"
+	"sync"
 	"time"
 	"unsafe"
 
@@ -26,11 +27,20 @@ const ENDPOINT = "https://api.deversifi.com"
 
 // Please do not send more than 10 requests per second. Sending requests more frequently will result in HTTP 429 errors.
 type Client struct {
-	privateKey string
-	subaccount string
-	HTTPC      *http.Client
+	privateKey  string
+	subaccount  string
+	HTTPC       *http.Client
+	OrderSigner OrderSigner
+	dryRun      *DryRun
+
+	limitMux sync.Mutex
+	limiter  *rateLimiter
+	limitSet bool
 }
 
+// requests per second of a client until SetRateLimit is called
+const DefaultRateLimit = 10
+
 func New(privateKey, subaccount string) *Client {
 	hc := &http.Client{
 		Timeout: 10 * time.Second,
@@ -42,6 +52,54 @@ func New(privateKey, subaccount string) *Client {
 	}
 }
 
+// SetRateLimit changes the requests per second of the client, zero or less turns the limit off.
+func (c *Client) SetRateLimit(perSecond int) {
+	c.limitMux.Lock()
+	defer c.limitMux.Unlock()
+	c.limitSet = true
+	c.limiter = nil
+	if perSecond > 0 {
+		c.limiter = newRateLimiter(perSecond)
+	}
+}
+
+// clients built without New get the default limit too
+func (c *Client) requestLimiter() *rateLimiter {
+	c.limitMux.Lock()
+	defer c.limitMux.Unlock()
+	if !c.limitSet {
+		c.limiter = newRateLimiter(DefaultRateLimit)
+		c.limitSet = true
+	}
+	return c.limiter
+}
+
+// spaces the requests out evenly, shared by every request of the client
+type rateLimiter struct {
+	mux  sync.Mutex
+	next time.Time
+	gap  time.Duration
+}
+
+func newRateLimiter(perSecond int) *rateLimiter {
+	return &rateLimiter{gap: time.Second / time.Duration(perSecond)}
+}
+
+func (r *rateLimiter) wait() {
+	if r == nil {
+		return
+	}
+	r.mux.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	wait := r.next.Sub(now)
+	r.next = r.next.Add(r.gap)
+	r.mux.Unlock()
+	time.Sleep(wait)
+}
+
 func (p *Client) newRequest(method, spath string, body []byte, params *map[string]string) (*http.Request, error) {
 	u, _ := url.ParseRequestURI(ENDPOINT)
 	u.Path = u.Path + spath
@@ -62,10 +120,16 @@ func (p *Client) newRequest(method, spath string, body []byte, params *map[strin
 }
 
 func (c *Client) sendRequest(method, spath string, body []byte, params *map[string]string) (*http.Response, error) {
+	if c.dryRun != nil {
+		if res, ok, err := c.dryRun.intercept(method, spath, body); ok {
+			return res, err
+		}
+	}
 	req, err := c.newRequest(method, spath, body, params)
 	if err != nil {
 		return nil, err
 	}
+	c.requestLimiter().wait()
 	res, err := c.HTTPC.Do(req)
 	if err != nil {
 		return nil, err
@@ -105,7 +169,7 @@ func (c *Client) Headers(request *http.Request) {
 func SocketEndPointHub(private bool) (endpoint string) {
 	switch private {
 	case true:
-		// pass
+		endpoint = "wss://api.deversifi.com/v1/trading/ws"
 	default:
 		endpoint = "wss://api.deversifi.com/market-data/ws"
 
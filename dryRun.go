@@ -0,0 +1,184 @@
+package dvfapi
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DryRun stands in for every write endpoint (/v1/trading/w/...) of the client: submitting and canceling orders,
+// registering, withdrawing. Nothing is sent, the requests are recorded and answered from memory.
+// Reads still go to the API, except openOrders, which only lists the paper orders,
+// and getOrder for the orders the simulator made up.
+type DryRun struct {
+	mux     sync.Mutex
+	records []DryRunRecord
+	orders  map[string]*SubmitOrderResponse
+	// order ids in submission order
+	placed  []string
+	created map[string]time.Time
+	seq     int
+}
+
+type DryRunRecord struct {
+	Time   time.Time
+	Method string
+	Path   string
+	Body   []byte
+}
+
+// EnableDryRun switches the client to paper mode, call it before the client is used.
+func (p *Client) EnableDryRun() *DryRun {
+	p.dryRun = &DryRun{
+		orders:  make(map[string]*SubmitOrderResponse),
+		created: make(map[string]time.Time),
+	}
+	return p.dryRun
+}
+
+func (p *Client) DisableDryRun() {
+	p.dryRun = nil
+}
+
+// every request the simulator answered, in order
+func (d *DryRun) Records() []DryRunRecord {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	records := make([]DryRunRecord, len(d.records))
+	copy(records, d.records)
+	return records
+}
+
+// orders placed in paper mode and still open
+func (d *DryRun) OpenOrders() []SubmitOrderResponse {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	var orders []SubmitOrderResponse
+	for _, id := range d.placed {
+		if order := d.orders[id]; order.Active {
+			orders = append(orders, *order)
+		}
+	}
+	return orders
+}
+
+// answer of openOrders, shaped like GetAllOrdersResponse
+func (d *DryRun) openOrders(symbol string) []map[string]interface{} {
+	orders := make([]map[string]interface{}, 0)
+	for _, id := range d.placed {
+		order := d.orders[id]
+		if !order.Active || (symbol != "" && order.Symbol != symbol) {
+			continue
+		}
+		orders = append(orders, map[string]interface{}{
+			"_id":         order.ID,
+			"symbol":      order.Symbol,
+			"amount":      order.Amount,
+			"totalFilled": order.TotalFilled,
+			"price":       order.Price,
+			"active":      order.Active,
+			"type":        order.Type,
+			"createdAt":   d.created[id],
+		})
+	}
+	return orders
+}
+
+// returns false when the request has to go to the API
+func (d *DryRun) intercept(method, spath string, body []byte) (*http.Response, bool, error) {
+	isWrite := strings.HasPrefix(spath, "/v1/trading/w/")
+	params := make(map[string]interface{})
+	if len(body) != 0 {
+		if err := json.Unmarshal(body, &params); err != nil && isWrite {
+			return nil, true, err
+		}
+	}
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if !isWrite {
+		switch spath {
+		case "/v1/trading/r/openOrders":
+			d.record(method, spath, body)
+			return dryRunResponse(d.openOrders(paramString(params, "symbol")))
+		case "/v1/trading/r/getOrder":
+			order, ok := d.orders[paramString(params, "orderId")]
+			if !ok {
+				return nil, false, nil
+			}
+			d.record(method, spath, body)
+			return dryRunResponse([]*SubmitOrderResponse{order})
+		}
+		return nil, false, nil
+	}
+	d.record(method, spath, body)
+	switch spath {
+	case "/v1/trading/w/submitOrder":
+		d.seq++
+		amount, _ := strconv.ParseFloat(paramString(params, "amount"), 64)
+		price, _ := strconv.ParseFloat(paramString(params, "price"), 64)
+		order := &SubmitOrderResponse{
+			ID:     fmt.Sprintf("dryrun-%d", d.seq),
+			Cid:    paramString(params, "cid"),
+			Type:   paramString(params, "type"),
+			Symbol: paramString(params, "symbol"),
+			Amount: amount,
+			Price:  price,
+			Active: true,
+		}
+		d.orders[order.ID] = order
+		d.placed = append(d.placed, order.ID)
+		d.created[order.ID] = time.Now()
+		return dryRunResponse(order)
+	case "/v1/trading/w/cancelOrder":
+		orderId := paramString(params, "orderId")
+		order, ok := d.orders[orderId]
+		if !ok || !order.Active {
+			return nil, true, fmt.Errorf("faild to get data. status: %s", "422 Unprocessable Entity (dry run: order not open)")
+		}
+		order.Active = false
+		order.Canceled = true
+		return dryRunResponse(CancelOrderResponse{OrderId: orderId, Canceled: true})
+	case "/v1/trading/w/register":
+		return dryRunResponse(map[string]interface{}{"isRegistered": true})
+	default:
+		return dryRunResponse(map[string]interface{}{})
+	}
+}
+
+func (d *DryRun) record(method, spath string, body []byte) {
+	d.records = append(d.records, DryRunRecord{
+		Time:   time.Now(),
+		Method: method,
+		Path:   spath,
+		Body:   append([]byte(nil), body...),
+	})
+}
+
+func dryRunResponse(v interface{}) (*http.Response, bool, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, true, err
+	}
+	res := &http.Response{
+		Status:     "200 OK",
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(b)),
+	}
+	return res, true, nil
+}
+
+func paramString(params map[string]interface{}, key string) string {
+	switch v := params[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
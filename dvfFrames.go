@@ -0,0 +1,171 @@
+package dvfapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DVFEvent is an object frame of the socket: info, conf, subscribed, unsubscribed or error.
+type DVFEvent struct {
+	Event   string  `json:"event"`
+	Channel string  `json:"channel"`
+	ChanID  float64 `json:"chanId"`
+	Symbol  string  `json:"symbol"`
+	Key     string  `json:"key"`
+	Code    int     `json:"code"`
+	Msg     string  `json:"msg"`
+	Version int     `json:"version"`
+	Status  string  `json:"status"`
+	Flags   int     `json:"flags"`
+}
+
+// DVFFrame is one message of the socket, either an event or a channel frame [chanId, (type,) payload].
+type DVFFrame struct {
+	Event  *DVFEvent
+	ChanID float64
+	// hb, cs, te, tu..., empty for plain data frames
+	Type string
+	// data of the frame, a list of rows when Snapshot is set
+	Payload  rawJSON
+	Snapshot bool
+	Checksum int32
+}
+
+// rawJSON keeps a part of the frame undecoded until its channel knows what it is
+type rawJSON []byte
+
+func (r *rawJSON) UnmarshalJSON(data []byte) error {
+	*r = append((*r)[:0], data...)
+	return nil
+}
+
+func (r rawJSON) MarshalJSON() ([]byte, error) {
+	if len(r) == 0 {
+		return []byte("null"), nil
+	}
+	return r, nil
+}
+
+// DecodeDVFFrame decodes a socket message, malformed frames return an error.
+func DecodeDVFFrame(data []byte) (*DVFFrame, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("the incoming message is empty")
+	}
+	switch data[0] {
+	case '{':
+		var event DVFEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, err
+		}
+		if event.Event == "" {
+			return nil, errors.New("object frame without event")
+		}
+		return &DVFFrame{Event: &event}, nil
+	case '[':
+	default:
+		return nil, fmt.Errorf("unexpected frame %.32q", data)
+	}
+	var parts []rawJSON
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return nil, err
+	}
+	if len(parts) < 2 {
+		return nil, errors.New("frame too short")
+	}
+	var frame DVFFrame
+	if err := json.Unmarshal(parts[0], &frame.ChanID); err != nil {
+		return nil, fmt.Errorf("frame without channel id: %w", err)
+	}
+	payload := parts[1]
+	if payload[0] == '"' {
+		if err := json.Unmarshal(payload, &frame.Type); err != nil {
+			return nil, err
+		}
+		switch {
+		case frame.Type == "hb":
+			return &frame, nil
+		case frame.Type == "cs":
+			if len(parts) < 3 {
+				return nil, errors.New("checksum frame without value")
+			}
+			var checksum int64
+			if err := json.Unmarshal(parts[2], &checksum); err != nil {
+				return nil, fmt.Errorf("bad checksum value: %w", err)
+			}
+			frame.Checksum = int32(checksum)
+			return &frame, nil
+		case len(parts) < 3:
+			return nil, fmt.Errorf("%s frame without data", frame.Type)
+		}
+		payload = parts[2]
+	}
+	if len(payload) == 0 || payload[0] != '[' {
+		return nil, errors.New("frame data is not an array")
+	}
+	// a list of rows is a snapshot, a single row is an update
+	inner := bytes.TrimSpace(payload[1:])
+	frame.Snapshot = len(inner) != 0 && (inner[0] == '[' || inner[0] == ']')
+	frame.Payload = payload
+	return &frame, nil
+}
+
+// BookEntry is a book row: [price, count, amount] for aggregated books, [orderId, price, amount] for raw books.
+type BookEntry [3]float64
+
+func (e *BookEntry) UnmarshalJSON(data []byte) error {
+	var row []float64
+	if err := json.Unmarshal(data, &row); err != nil {
+		return fmt.Errorf("bad book entry: %w", err)
+	}
+	if len(row) != 3 {
+		return fmt.Errorf("book entry with %d fields, want 3", len(row))
+	}
+	copy(e[:], row)
+	return nil
+}
+
+// BookEntries decodes the payload of a book frame.
+func (f *DVFFrame) BookEntries() ([]BookEntry, error) {
+	if len(f.Payload) == 0 {
+		return nil, errors.New("frame has no book data")
+	}
+	if f.Snapshot {
+		var entries []BookEntry
+		if err := json.Unmarshal(f.Payload, &entries); err != nil {
+			return nil, err
+		}
+		return entries, nil
+	}
+	var entry BookEntry
+	if err := json.Unmarshal(f.Payload, &entry); err != nil {
+		return nil, err
+	}
+	return []BookEntry{entry}, nil
+}
+
+type BookMessageKind int
+
+const (
+	MessageNone BookMessageKind = iota
+	MessageSnapshot
+	MessageUpdate
+	MessageChecksum
+	MessageHeartbeat
+)
+
+// BookMessage is what the socket hands to the book maintainer.
+type BookMessage struct {
+	Kind     BookMessageKind
+	Entries  []BookEntry
+	Checksum int32
+	// when the frame came off the socket
+	Received time.Time
+}
+
+func rawOrderID(id float64) string {
+	return strconv.FormatFloat(id, 'f', -1, 64)
+}
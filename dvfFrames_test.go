@@ -0,0 +1,53 @@
+package dvfapi
+
+import "testing"
+
+func FuzzDecodeDVFFrame(f *testing.F) {
+	seeds := []string{
+		`{"event":"info","version":2}`,
+		`{"event":"subscribed","channel":"book","chanId":5,"symbol":"ETH:USDT"}`,
+		`{"event":"error","code":10300,"msg":"Subscription failed"}`,
+		`[5,"hb"]`,
+		`[5,"cs",-1234567]`,
+		`[5,[[100.5,2,3],[101,1,-1.25]]]`,
+		`[5,[]]`,
+		`[5,[100.5,0,1]]`,
+		`[5,"te",[1,1650000000000,0.5,100]]`,
+		`[5,[[1e-7,1,2e21]]]`,
+		`[5]`,
+		`[null,[1,2,3]]`,
+		`["5",[1,2,3]]`,
+		`[5,"cs","x"]`,
+		`{}`,
+		` `,
+		`hello`,
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		frame, err := DecodeDVFFrame(data)
+		if err != nil {
+			if frame != nil {
+				t.Fatalf("frame returned with error %v", err)
+			}
+			return
+		}
+		if frame.Event != nil {
+			if frame.Event.Event == "" {
+				t.Fatal("event frame without event")
+			}
+			return
+		}
+		if frame.Type != "" {
+			return
+		}
+		entries, err := frame.BookEntries()
+		if err != nil {
+			return
+		}
+		if !frame.Snapshot && len(entries) != 1 {
+			t.Fatalf("update with %d entries", len(entries))
+		}
+	})
+}
@@ -1,7 +1,11 @@
 package dvfapi
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type GetConfigResponse struct {
@@ -84,3 +88,61 @@ func (p *Client) GetConfig() (result *GetConfigResponse, err error) {
 	result = &r
 	return result, nil
 }
+
+// Tickers returns the ticker of every symbol asked, example: ETH:USDT
+func (p *Client) Tickers(symbols ...string) (result []Ticker, err error) {
+	params := make(map[string]string)
+	params["symbols"] = strings.Join(symbols, ",")
+	res, err := p.sendRequest(http.MethodGet, "/market-data/tickers", nil, &params)
+	if err != nil {
+		return nil, err
+	}
+	err = decode(res, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (p *Client) Ticker(symbol string) (result *Ticker, err error) {
+	tickers, err := p.Tickers(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if len(tickers) == 0 {
+		return nil, fmt.Errorf("no ticker for %s", symbol)
+	}
+	return &tickers[0], nil
+}
+
+// rows per candles request
+const candlesPageLimit = 1000
+
+// GetCandles returns the bars of the key between start and end, oldest first, paging through the history.
+// key example: trade:1m:ETH:USDT
+func (p *Client) GetCandles(key string, start, end time.Time) (result []Candle, err error) {
+	from := start.UnixMilli()
+	to := end.UnixMilli()
+	for from <= to {
+		params := make(map[string]string)
+		params["start"] = strconv.FormatInt(from, 10)
+		params["end"] = strconv.FormatInt(to, 10)
+		params["limit"] = strconv.Itoa(candlesPageLimit)
+		params["sort"] = "1"
+		res, err := p.sendRequest(http.MethodGet, "/market-data/candles/"+key+"/hist", nil, &params)
+		if err != nil {
+			return nil, err
+		}
+		var page []Candle
+		err = decode(res, &page)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, page...)
+		if len(page) < candlesPageLimit {
+			break
+		}
+		from = page[len(page)-1].Time.UnixMilli() + 1
+	}
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package dvfapi
+
+import (
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExecutionRequest describes a taker order to estimate, set either BaseAmount or QuoteNotional.
+type ExecutionRequest struct {
+	// buy walks the asks, sell walks the bids
+	Buy           bool
+	BaseAmount    decimal.Decimal
+	QuoteNotional decimal.Decimal
+	// taker fee as a fraction, see GetFeeRateResponse.TakerRate
+	FeeRate decimal.Decimal
+}
+
+type ExecutionEstimate struct {
+	// base filled and quote exchanged, before fees
+	Filled   decimal.Decimal
+	Notional decimal.Decimal
+	// volume weighted average and last level price
+	AvgPrice   decimal.Decimal
+	WorstPrice decimal.Decimal
+	// distance of AvgPrice from mid, positive when worse than mid
+	SlippageBps decimal.Decimal
+	Levels      int
+	// the book was deep enough for the whole request
+	Sufficient bool
+	// fee in quote, net quote paid (buy) or received (sell) and the price it makes per base
+	Fee            decimal.Decimal
+	NetNotional    decimal.Decimal
+	EffectivePrice decimal.Decimal
+}
+
+// EstimateExecution walks the book like a taker order would and reports what it would cost.
+// Returns false if the book is not ready.
+func (o *OrderBookBranch) EstimateExecution(req ExecutionRequest) (ExecutionEstimate, bool, error) {
+	var est ExecutionEstimate
+	byBase := req.BaseAmount.IsPositive()
+	if byBase == req.QuoteNotional.IsPositive() {
+		return est, false, errors.New("set either a positive base amount or a positive quote notional")
+	}
+	mid, ok := o.Mid()
+	if !ok {
+		return est, false, nil
+	}
+	side := &o.Bids
+	if req.Buy {
+		side = &o.Asks
+	}
+	side.mux.RLock()
+	side.levels.each(func(price, qty decimal.Decimal) bool {
+		take, value := qty, qty.Mul(price)
+		if byBase {
+			if left := req.BaseAmount.Sub(est.Filled); take.GreaterThanOrEqual(left) {
+				take, value = left, left.Mul(price)
+				est.Sufficient = true
+			}
+		} else {
+			// keep the notional exact on the last level, only the base amount is rounded
+			if left := req.QuoteNotional.Sub(est.Notional); value.GreaterThanOrEqual(left) {
+				take, value = left.Div(price), left
+				est.Sufficient = true
+			}
+		}
+		est.Filled = est.Filled.Add(take)
+		est.Notional = est.Notional.Add(value)
+		est.WorstPrice = price
+		est.Levels++
+		return !est.Sufficient
+	})
+	side.mux.RUnlock()
+	if est.Filled.IsZero() {
+		return est, true, nil
+	}
+	est.AvgPrice = est.Notional.Div(est.Filled)
+	est.SlippageBps = est.AvgPrice.Sub(mid).Div(mid).Mul(bpsFactor)
+	if !req.Buy {
+		est.SlippageBps = est.SlippageBps.Neg()
+	}
+	est.Fee = est.Notional.Mul(req.FeeRate)
+	if req.Buy {
+		est.NetNotional = est.Notional.Add(est.Fee)
+	} else {
+		est.NetNotional = est.Notional.Sub(est.Fee)
+	}
+	est.EffectivePrice = est.NetNotional.Div(est.Filled)
+	return est, true, nil
+}
@@ -1,6 +1,6 @@
 module github.com/dpong/Okex_RESTapi
 
-go 1.17
+go 1.18
 
 require (
 	github.com/ethereum/go-ethereum v1.10.15
@@ -8,6 +8,7 @@ require (
 	github.com/json-iterator/go v1.1.12
 	github.com/shopspring/decimal v1.3.1
 	github.com/sirupsen/logrus v1.8.1
+	go.uber.org/goleak v1.1.12
 )
 
 require (
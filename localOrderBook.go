@@ -3,8 +3,13 @@ package dvfapi
 import (
 	"context"
 	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -20,16 +25,90 @@ type OrderBookBranch struct {
 	Cancel        *context.CancelFunc
 	reCh          chan error
 	lastRefresh   lastRefreshBranch
+	lastUpdate    lastRefreshBranch
+	// checksum frames that did not match the local book
+	checksumFailures uint64
+	// individual orders, only for R0 subscriptions
+	raw    *rawBook
+	events bookHub
+	top    topOfBook
+	// level changes applied, moves under the side lock
+	seq     uint64
+	health  bookHealth
+	latency latencyStats
+	// goroutines of the book, Close waits for them
+	wg sync.WaitGroup
 }
 
+// how long Close waits for the goroutines of a book
+const bookCloseTimeout = 5 * time.Second
+
 type lastRefreshBranch struct {
 	mux  sync.RWMutex
 	time time.Time
 }
 
+// BookBranch is one side of the book, levels are sorted on price with the best first.
 type BookBranch struct {
-	mux  sync.RWMutex
-	Book [][]string
+	mux    sync.RWMutex
+	levels *priceLevels
+	// [][]string view of the levels, rebuilt on read after a change
+	viewMux sync.Mutex
+	view    [][]string
+	dirty   bool
+}
+
+func (b *BookBranch) init(desc bool) {
+	b.levels = newPriceLevels(desc)
+	b.view = [][]string{}
+}
+
+// caller holds mux, returns false if the level did not change
+func (b *BookBranch) set(price, qty decimal.Decimal) bool {
+	if !b.levels.set(price, qty) {
+		return false
+	}
+	b.viewMux.Lock()
+	b.dirty = true
+	b.viewMux.Unlock()
+	return true
+}
+
+// caller holds mux
+func (b *BookBranch) reset() {
+	b.levels.clear()
+	b.viewMux.Lock()
+	b.view = [][]string{}
+	b.dirty = false
+	b.viewMux.Unlock()
+}
+
+// caller holds mux at least for reading
+func (b *BookBranch) bookView() [][]string {
+	b.viewMux.Lock()
+	defer b.viewMux.Unlock()
+	if b.dirty {
+		view := make([][]string, 0, b.levels.len())
+		b.levels.each(func(price, qty decimal.Decimal) bool {
+			view = append(view, []string{price.String(), qty.String()})
+			return true
+		})
+		b.view = view
+		b.dirty = false
+	}
+	return b.view
+}
+
+// Book returns a copy of the side as [price, qty] strings, best price first.
+// It replaces the former Book field, callers reading o.Bids.Book have to call o.Bids.Book() now.
+func (b *BookBranch) Book() [][]string {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return copyBook(b.bookView())
+}
+
+func (b *BookBranch) len() int {
+	return b.levels.len()
 }
 
 func (o *OrderBookBranch) IfCanRefresh() bool {
@@ -43,159 +122,175 @@ func (o *OrderBookBranch) IfCanRefresh() bool {
 	return false
 }
 
-func (o *OrderBookBranch) UpdateNewComing(message *map[string]interface{}) {
-	var wg sync.WaitGroup
-	data := (*message)["data"].([]interface{})
-	for _, item := range data {
-		if book, ok := item.(map[string]interface{}); ok {
-			if bids, ok := book["bids"].([]interface{}); ok {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					for _, item := range bids {
-						if levelData, ok := item.([]interface{}); ok {
-							price, okPrice := levelData[0].(string)
-							size, okSize := levelData[1].(string)
-							if !okPrice || !okSize {
-								continue
-							}
-							decPrice, _ := decimal.NewFromString(price)
-							decSize, _ := decimal.NewFromString(size)
-							o.DealWithBidPriceLevel(decPrice, decSize)
-						}
-					}
-				}()
-			}
-			if asks, ok := book["asks"].([]interface{}); ok {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					for _, item := range asks {
-						if levelData, ok := item.([]interface{}); ok {
-							price, okPrice := levelData[0].(string)
-							size, okSize := levelData[1].(string)
-							if !okPrice || !okSize {
-								continue
-							}
-							decPrice, _ := decimal.NewFromString(price)
-							decSize, _ := decimal.NewFromString(size)
-							o.DealWithAskPriceLevel(decPrice, decSize)
-						}
-					}
-				}()
-			}
-			wg.Wait()
-		}
-	}
+// time of the last message received from the feed
+func (o *OrderBookBranch) LastUpdate() time.Time {
+	o.lastUpdate.mux.RLock()
+	defer o.lastUpdate.mux.RUnlock()
+	return o.lastUpdate.time
+}
+
+func (o *OrderBookBranch) touch() {
+	o.lastUpdate.mux.Lock()
+	o.lastUpdate.time = time.Now()
+	o.lastUpdate.mux.Unlock()
 }
 
 func (o *OrderBookBranch) DealWithBidPriceLevel(price, qty decimal.Decimal) {
 	o.Bids.mux.Lock()
-	defer o.Bids.mux.Unlock()
-	l := len(o.Bids.Book)
-	if l == 0 {
-		o.Bids.Book = append(o.Bids.Book, []string{price.String(), qty.String()})
-		return
+	changed := o.Bids.set(price, qty)
+	if changed {
+		atomic.AddUint64(&o.seq, 1)
+		o.top.update(true, o.Bids.levels.first())
 	}
-	for level, item := range o.Bids.Book {
-		bookPrice, _ := decimal.NewFromString(item[0])
-		switch {
-		case price.GreaterThan(bookPrice):
-			// insert level
-			if qty.IsZero() {
-				// ignore
-				return
-			}
-			o.Bids.Book = append(o.Bids.Book, []string{})
-			copy(o.Bids.Book[level+1:], o.Bids.Book[level:])
-			o.Bids.Book[level] = []string{price.String(), qty.String()}
-			return
-		case price.LessThan(bookPrice):
-			if level == l-1 {
-				// insert last level
-				if qty.IsZero() {
-					// ignore
-					return
-				}
-				o.Bids.Book = append(o.Bids.Book, []string{price.String(), qty.String()})
-				return
-			}
-			continue
-		case price.Equal(bookPrice):
-			if qty.IsZero() {
-				// delete level
-				o.Bids.Book = append(o.Bids.Book[:level], o.Bids.Book[level+1:]...)
-				return
-			}
-			o.Bids.Book[level][1] = qty.String()
-			return
-		}
+	o.Bids.mux.Unlock()
+	if changed {
+		o.publishLevel(true, price, qty)
 	}
 }
 
 func (o *OrderBookBranch) DealWithAskPriceLevel(price, qty decimal.Decimal) {
 	o.Asks.mux.Lock()
-	defer o.Asks.mux.Unlock()
-	l := len(o.Asks.Book)
-	if l == 0 {
-		o.Asks.Book = append(o.Asks.Book, []string{price.String(), qty.String()})
-		return
+	changed := o.Asks.set(price, qty)
+	if changed {
+		atomic.AddUint64(&o.seq, 1)
+		o.top.update(false, o.Asks.levels.first())
 	}
-	for level, item := range o.Asks.Book {
-		bookPrice, _ := decimal.NewFromString(item[0])
-		switch {
-		case price.LessThan(bookPrice):
-			// insert level
-			if qty.IsZero() {
-				// ignore
-				return
-			}
-			o.Asks.Book = append(o.Asks.Book, []string{})
-			copy(o.Asks.Book[level+1:], o.Asks.Book[level:])
-			o.Asks.Book[level] = []string{price.String(), qty.String()}
-			return
-		case price.GreaterThan(bookPrice):
-			if level == l-1 {
-				// insert last level
-				if qty.IsZero() {
-					// ignore
-					return
-				}
-				o.Asks.Book = append(o.Asks.Book, []string{price.String(), qty.String()})
-				return
-			}
-			continue
-		case price.Equal(bookPrice):
-			if qty.IsZero() {
-				// delete level
-				o.Asks.Book = append(o.Asks.Book[:level], o.Asks.Book[level+1:]...)
-				return
-			}
-			o.Asks.Book[level][1] = qty.String()
-			return
+	o.Asks.mux.Unlock()
+	if changed {
+		o.publishLevel(false, price, qty)
+	}
+}
+
+// clear both sides before a new snapshot or on close
+func (o *OrderBookBranch) resetBook() {
+	o.SnapShoted = false
+	o.Bids.mux.Lock()
+	o.Bids.reset()
+	o.top.update(true, nil)
+	o.Bids.mux.Unlock()
+	o.Asks.mux.Lock()
+	o.Asks.reset()
+	o.top.update(false, nil)
+	o.Asks.mux.Unlock()
+	if o.raw != nil {
+		o.raw.reset()
+	}
+	o.publishReset()
+}
+
+// levels per side covered by the checksum frames
+const checksumDepth = 25
+
+// Checksum of the top levels, computed the way the exchange does:
+// crc32 of bid price, bid amount, ask price, negative ask amount for each level, joined with ":".
+// Raw books use the top orders instead, with the order id in place of the price.
+func (o *OrderBookBranch) Checksum() int32 {
+	o.Bids.mux.RLock()
+	defer o.Bids.mux.RUnlock()
+	o.Asks.mux.RLock()
+	defer o.Asks.mux.RUnlock()
+	var bids, asks [][2]string
+	if o.raw != nil {
+		bids = o.raw.topOrders(o.Bids.levels, true, checksumDepth)
+		asks = o.raw.topOrders(o.Asks.levels, false, checksumDepth)
+	} else {
+		bids = topLevels(o.Bids.levels, true, checksumDepth)
+		asks = topLevels(o.Asks.levels, false, checksumDepth)
+	}
+	var parts []string
+	for i := 0; i < checksumDepth; i++ {
+		if i < len(bids) {
+			parts = append(parts, bids[i][0], bids[i][1])
+		}
+		if i < len(asks) {
+			parts = append(parts, asks[i][0], asks[i][1])
 		}
 	}
+	return int32(crc32.ChecksumIEEE([]byte(strings.Join(parts, ":"))))
+}
+
+// [price, amount] of the first n levels, amount negative for asks
+func topLevels(levels *priceLevels, bid bool, n int) [][2]string {
+	top := make([][2]string, 0, n)
+	for x := levels.first(); x != nil && len(top) < n; x = x.next[0] {
+		qty := x.qty
+		if !bid {
+			qty = qty.Neg()
+		}
+		top = append(top, [2]string{checksumNumber(x.price), checksumNumber(qty)})
+	}
+	return top
+}
+
+// the exchange prints numbers like javascript: shortest digits, exponent below 1e-6 and from 1e21
+func checksumNumber(d decimal.Decimal) string {
+	f, _ := d.Float64()
+	abs := math.Abs(f)
+	if abs == 0 {
+		return "0"
+	}
+	if abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	// go pads the exponent to two digits, javascript does not: 1e-07 -> 1e-7
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	at := strings.IndexByte(s, 'e')
+	return s[:at+2] + strings.TrimLeft(s[at+2:], "0")
+}
+
+func (o *OrderBookBranch) ChecksumFailures() uint64 {
+	return atomic.LoadUint64(&o.checksumFailures)
+}
+
+func (o *OrderBookBranch) verifyChecksum(value int32) {
+	if !o.SnapShoted {
+		return
+	}
+	if local := o.Checksum(); local != value {
+		atomic.AddUint64(&o.checksumFailures, 1)
+		o.RefreshLocalOrderBook(fmt.Errorf("checksum mismatch, local %d remote %d", local, value))
+	}
 }
 
 func (o *OrderBookBranch) RefreshLocalOrderBook(err error) error {
 	if o.IfCanRefresh() {
-		if len(o.reCh) == cap(o.reCh) {
+		select {
+		case o.reCh <- err:
+		default:
 			return errors.New("refresh channel is full, please check it up")
 		}
-		o.reCh <- err
 	}
 	return nil
 }
 
-func (o *OrderBookBranch) Close() {
+// Close stops the book, closes its subscriptions and waits for its goroutines,
+// an error means some of them are still running.
+func (o *OrderBookBranch) Close() error {
 	(*o.Cancel)()
-	o.SnapShoted = false
-	o.Bids.mux.Lock()
-	o.Bids.Book = [][]string{}
-	o.Bids.mux.Unlock()
-	o.Asks.mux.Lock()
-	o.Asks.Book = [][]string{}
-	o.Asks.mux.Unlock()
+	o.events.close()
+	done := make(chan struct{})
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-time.After(bookCloseTimeout):
+		err = fmt.Errorf("orderbook goroutines still running after %s", bookCloseTimeout)
+	}
+	o.resetBook()
+	o.setState(Closed)
+	return err
+}
+
+// run fn in a goroutine that Close waits for
+func (o *OrderBookBranch) goes(fn func()) {
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		fn()
+	}()
 }
 
 // return bids, ready or not
@@ -205,37 +300,32 @@ func (o *OrderBookBranch) GetBids() ([][]string, bool) {
 	if !o.SnapShoted {
 		return [][]string{}, false
 	}
-	if len(o.Bids.Book) == 0 {
-		if o.IfCanRefresh() {
-			o.reCh <- errors.New("re cause len bid is zero")
-		}
+	if o.Bids.len() == 0 {
+		o.RefreshLocalOrderBook(errors.New("re cause len bid is zero"))
 		return [][]string{}, false
 	}
-	book := o.Bids.Book
+	book := copyBook(o.Bids.bookView())
 	return book, true
 }
 
 func (o *OrderBookBranch) GetBidsEnoughForValue(value decimal.Decimal) ([][]string, bool) {
 	o.Bids.mux.RLock()
 	defer o.Bids.mux.RUnlock()
-	if len(o.Bids.Book) == 0 || !o.SnapShoted {
+	if o.Bids.len() == 0 || !o.SnapShoted {
 		return [][]string{}, false
 	}
-	var loc int
+	var loc, level int
 	var sumValue decimal.Decimal
-	for level, data := range o.Bids.Book {
-		if len(data) != 2 {
-			return [][]string{}, false
-		}
-		price, _ := decimal.NewFromString(data[0])
-		size, _ := decimal.NewFromString(data[1])
+	o.Bids.levels.each(func(price, size decimal.Decimal) bool {
 		sumValue = sumValue.Add(price.Mul(size))
 		if sumValue.GreaterThan(value) {
 			loc = level
-			break
+			return false
 		}
-	}
-	book := o.Bids.Book[:loc+1]
+		level++
+		return true
+	})
+	book := copyBook(o.Bids.bookView()[:loc+1])
 	return book, true
 }
 
@@ -246,63 +336,70 @@ func (o *OrderBookBranch) GetAsks() ([][]string, bool) {
 	if !o.SnapShoted {
 		return [][]string{}, false
 	}
-	if len(o.Asks.Book) == 0 {
-		if o.IfCanRefresh() {
-			o.reCh <- errors.New("re cause len ask is zero")
-		}
+	if o.Asks.len() == 0 {
+		o.RefreshLocalOrderBook(errors.New("re cause len ask is zero"))
 		return [][]string{}, false
 	}
-	book := o.Asks.Book
+	book := copyBook(o.Asks.bookView())
 	return book, true
 }
 
 func (o *OrderBookBranch) GetAsksEnoughForValue(value decimal.Decimal) ([][]string, bool) {
 	o.Asks.mux.RLock()
 	defer o.Asks.mux.RUnlock()
-	if len(o.Asks.Book) == 0 || !o.SnapShoted {
+	if o.Asks.len() == 0 || !o.SnapShoted {
 		return [][]string{}, false
 	}
-	var loc int
+	var loc, level int
 	var sumValue decimal.Decimal
-	for level, data := range o.Asks.Book {
-		if len(data) != 2 {
-			return [][]string{}, false
-		}
-		price, _ := decimal.NewFromString(data[0])
-		size, _ := decimal.NewFromString(data[1])
+	o.Asks.levels.each(func(price, size decimal.Decimal) bool {
 		sumValue = sumValue.Add(price.Mul(size))
 		if sumValue.GreaterThan(value) {
 			loc = level
-			break
+			return false
 		}
-	}
-	book := o.Asks.Book[:loc+1]
+		level++
+		return true
+	})
+	book := copyBook(o.Asks.bookView()[:loc+1])
 	return book, true
 }
 
 // symbol example: ETH:USDT
 func LocalOrderBook(symbol string, logger *log.Logger) *OrderBookBranch {
-	var o OrderBookBranch
+	o, _ := LocalOrderBookWithOptions(symbol, logger, BookOptions{})
+	return o
+}
+
+// symbol example: ETH:USDT
+func LocalOrderBookWithOptions(symbol string, logger *log.Logger, opt BookOptions) (*OrderBookBranch, error) {
+	return localOrderBook(SocketEndPointHub(false), symbol, logger, opt)
+}
+
+func localOrderBook(url, symbol string, logger *log.Logger, opt BookOptions) (*OrderBookBranch, error) {
+	if err := opt.Validate(); err != nil {
+		return nil, err
+	}
+	o := newOrderBookBranch(opt)
 	ctx, cancel := context.WithCancel(context.Background())
 	o.Cancel = &cancel
-	bookticker := make(chan map[string]interface{}, 50)
+	bookticker := make(chan *BookMessage, 50)
 	refreshCh := make(chan error, 5)
-	o.reCh = make(chan error, 5)
 	symbol = strings.ToUpper(symbol)
-	url := SocketEndPointHub(false)
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				if err := DVFOrderBookSocket(ctx, url, symbol, "orderbook", logger, &bookticker, &refreshCh); err == nil {
-					return
-				}
+	retry := newBackoff(opt.Reconnect)
+	o.goes(func() {
+		keepConnected(ctx, symbol+" orderbook", retry, logger, func(ctx context.Context) error {
+			o.connecting()
+			lives := o.liveCount()
+			err := DVFOrderBookSocket(ctx, url, symbol, "orderbook", opt, logger, &bookticker, &refreshCh)
+			if err != nil && o.liveCount() != lives {
+				// the session worked, this is a new outage
+				retry.reset()
 			}
-		}
-	}()
-	go func() {
+			return err
+		})
+	})
+	o.goes(func() {
 		for {
 			select {
 			case <-ctx.Done():
@@ -313,19 +410,36 @@ func LocalOrderBook(symbol string, logger *log.Logger) *OrderBookBranch {
 					return
 				}
 				logger.Warningf("refreshing %s local orderbook cause: %s", symbol, err.Error())
-				refreshCh <- errors.New("refreshing from maintain orderbook")
+				o.setState(Resyncing)
+				select {
+				case refreshCh <- errors.New("refreshing from maintain orderbook"):
+				case <-ctx.Done():
+					return
+				}
 			}
 		}
-	}()
+	})
+	o.goes(func() { o.monitorHealth(ctx) })
+	return o, nil
+}
+
+func newOrderBookBranch(opt BookOptions) *OrderBookBranch {
+	var o OrderBookBranch
+	o.Bids.init(true)
+	o.Asks.init(false)
+	if opt.Precision == "R0" {
+		o.raw = newRawBook()
+	}
+	o.reCh = make(chan error, 5)
+	o.health.init(opt.StaleAfter)
 	return &o
 }
 
 func (o *OrderBookBranch) MaintainOrderBook(
 	ctx context.Context,
 	symbol string,
-	bookticker *chan map[string]interface{},
+	bookticker *chan *BookMessage,
 ) error {
-	//var storage []map[string]interface{}
 	o.SnapShoted = false
 	o.LastUpdatedId = decimal.NewFromInt(0)
 	for {
@@ -334,28 +448,48 @@ func (o *OrderBookBranch) MaintainOrderBook(
 			return nil
 		case err := <-o.reCh:
 			return err
-		default:
-			message := <-(*bookticker)
-			if len(message) != 0 {
-				// for initial orderbook
-				if snapshot, ok := message["snapshot"]; ok {
-					o.InitialOrderBook(&snapshot)
-					continue
-				}
-				if update, ok := message["update"]; ok {
-					o.SpotUpdateJudge(&update)
-					continue
-				}
+		case message := <-*bookticker:
+			o.ApplyMessage(message)
+		}
+	}
+}
+
+// ApplyMessage mutates the book with one message of the feed.
+func (o *OrderBookBranch) ApplyMessage(message *BookMessage) {
+	if message == nil || message.Kind == MessageNone {
+		return
+	}
+	o.received()
+	switch message.Kind {
+	case MessageSnapshot:
+		// for initial orderbook
+		if o.raw != nil {
+			o.InitialRawOrderBook(message.Entries)
+		} else {
+			o.InitialOrderBook(message.Entries)
+		}
+	case MessageUpdate:
+		for _, entry := range message.Entries {
+			if o.raw != nil {
+				o.RawUpdateJudge(entry)
+			} else {
+				o.SpotUpdateJudge(entry)
 			}
 		}
+		if !message.Received.IsZero() {
+			o.latency.observe(time.Since(message.Received))
+		}
+		o.checkCrossed()
+	case MessageChecksum:
+		o.verifyChecksum(message.Checksum)
 	}
 }
 
-func (o *OrderBookBranch) SpotUpdateJudge(res *interface{}) {
-	data := (*res).([]interface{})
-	price := decimal.NewFromFloat(data[0].(float64))
-	qty := decimal.NewFromFloat(data[2].(float64))
-	count := data[1].(float64)
+// entry is [price, count, amount], positive amount for bids, zero count removes the level
+func (o *OrderBookBranch) SpotUpdateJudge(entry BookEntry) {
+	price := decimal.NewFromFloat(entry[0])
+	qty := decimal.NewFromFloat(entry[2])
+	count := entry[1]
 	realQty := qty.Abs()
 	if count == 0 {
 		realQty = decimal.Zero
@@ -367,13 +501,11 @@ func (o *OrderBookBranch) SpotUpdateJudge(res *interface{}) {
 	}
 }
 
-func (o *OrderBookBranch) InitialOrderBook(res *interface{}) {
-	//var wg sync.WaitGroup
-	set := (*res).([]interface{})
-	for _, item := range set {
-		data := item.([]interface{})
-		price := decimal.NewFromFloat(data[0].(float64))
-		qty := decimal.NewFromFloat(data[2].(float64))
+func (o *OrderBookBranch) InitialOrderBook(entries []BookEntry) {
+	o.resetBook()
+	for _, entry := range entries {
+		price := decimal.NewFromFloat(entry[0])
+		qty := decimal.NewFromFloat(entry[2])
 		if qty.IsPositive() {
 			o.DealWithBidPriceLevel(price, qty)
 		} else {
@@ -381,6 +513,8 @@ func (o *OrderBookBranch) InitialOrderBook(res *interface{}) {
 		}
 	}
 	o.SnapShoted = true
+	o.setState(Live)
+	o.publishSnapshot()
 }
 
 type DVFWebsocket struct {
@@ -390,45 +524,48 @@ type DVFWebsocket struct {
 	Conn          *websocket.Conn
 	LastUpdatedId decimal.Decimal
 	ChannelID     float64
+	// receive time of the frame being handled, travels with the book data to measure latency
+	received time.Time
 }
 
-type DVFSubscribeMessage struct {
-	Event   string `json:"event"`
-	Channel string `json:"channel"`
-	Symbol  string `json:"symbol"`
-}
+// conf flag asking the server to send a checksum frame after every book update
+const DVFChecksumFlag = 131072
 
-func (w *DVFWebsocket) OutDVFErr() map[string]interface{} {
-	w.OnErr = true
-	m := make(map[string]interface{})
-	return m
+type DVFConfMessage struct {
+	Event string `json:"event"`
+	Flags int    `json:"flags"`
 }
 
-func DecodingMap(message *[]byte, logger *log.Logger) (res interface{}, err error) {
-	if *message == nil {
-		err = errors.New("the incoming message is nil")
-		return nil, err
-	}
-	err = json.Unmarshal(*message, &res)
+func GetDVFConfMessage(flags int) []byte {
+	by, err := json.Marshal(DVFConfMessage{Event: "conf", Flags: flags})
 	if err != nil {
-		return nil, err
+		return nil
 	}
-	return res, nil
+	return by
+}
+
+type DVFSubscribeMessage struct {
+	Event   string `json:"event"`
+	Channel string `json:"channel"`
+	Symbol  string `json:"symbol,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Prec    string `json:"prec,omitempty"`
+	Freq    string `json:"freq,omitempty"`
+	Len     string `json:"len,omitempty"`
 }
 
 func DVFOrderBookSocket(
 	ctx context.Context,
 	url, symbol, channel string,
+	opt BookOptions,
 	logger *log.Logger,
-	mainCh *chan map[string]interface{},
+	mainCh *chan *BookMessage,
 	refreshCh *chan error,
 ) error {
 	var w DVFWebsocket
-	var duration time.Duration = 30
 	w.Logger = logger
 	w.OnErr = false
 	w.ChannelID = 0
-	innerErr := make(chan error, 1)
 	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
 	if err != nil {
 		return err
@@ -436,98 +573,158 @@ func DVFOrderBookSocket(
 	logger.Infof("DVF %s orderBook socket connected.\n", symbol)
 	w.Conn = conn
 	defer conn.Close()
-	send := GetDVFSubscribeMessage(channel, symbol)
-	if err := w.Conn.WriteMessage(websocket.TextMessage, send); err != nil {
+	if err := w.Conn.WriteMessage(websocket.TextMessage, GetDVFConfMessage(DVFChecksumFlag)); err != nil {
 		return err
 	}
-	if err := w.Conn.SetReadDeadline(time.Now().Add(time.Second * duration)); err != nil {
+	send := GetDVFBookSubscribeMessage(symbol, opt)
+	if err := w.Conn.WriteMessage(websocket.TextMessage, send); err != nil {
 		return err
 	}
-	read := time.NewTicker(time.Millisecond * 50)
-	defer read.Stop()
+	frames, stop := readFrames(conn)
+	defer stop()
+	ping := time.NewTicker(socketPingPeriod)
+	defer ping.Stop()
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		case err := <-*refreshCh:
-			innerErr <- errors.New("restart")
 			return err
-		case <-read.C:
-			if conn == nil {
-				d := w.OutDVFErr()
-				*mainCh <- d
-				message := "DVF reconnect..."
-				logger.Infoln(message)
-				innerErr <- errors.New("restart")
-				return errors.New(message)
+		case <-ping.C:
+			if err := sendPing(conn); err != nil {
+				w.OnErr = true
+				logger.Infoln("DVF reconnect...", err)
+				return err
 			}
-			_, buf, err := conn.ReadMessage()
-			if err != nil {
-				d := w.OutDVFErr()
-				*mainCh <- d
+		case frame, ok := <-frames:
+			if !ok || frame.err != nil {
+				w.OnErr = true
 				message := "DVF reconnect..."
 				logger.Infoln(message)
-				innerErr <- errors.New("restart")
 				return errors.New(message)
 			}
-			res, err1 := DecodingMap(&buf, logger)
+			if opt.Recorder != nil {
+				if err := opt.Recorder.Record(frame.received, frame.data); err != nil {
+					logger.Warningf("DVF %s fail to record frame: %s", symbol, err.Error())
+				}
+			}
+			res, err1 := DecodeDVFFrame(frame.data)
 			if err1 != nil {
-				d := w.OutDVFErr()
-				*mainCh <- d
+				w.OnErr = true
 				message := "DVF reconnect..."
 				logger.Infoln(message, err1)
-				innerErr <- errors.New("restart")
 				return err1
 			}
-			err2 := w.HandleDVFSocketData(&res, mainCh)
+			w.received = frame.received
+			err2 := w.HandleDVFFrame(ctx, res, mainCh)
 			if err2 != nil {
-				d := w.OutDVFErr()
-				*mainCh <- d
+				w.OnErr = true
 				message := "DVF reconnect..."
 				logger.Infoln(message, err2)
-				innerErr <- errors.New("restart")
 				return err2
 			}
-			if err := w.Conn.SetReadDeadline(time.Now().Add(time.Second * duration)); err != nil {
-				return err
-			}
-		default:
-			time.Sleep(time.Millisecond * 10)
 		}
 	}
 }
 
-func (w *DVFWebsocket) HandleDVFSocketData(res *interface{}, mainCh *chan map[string]interface{}) error {
-	if dataSet, ok := (*res).([]interface{}); ok {
-		if w.ChannelID == 0 {
-			// initial orderbook
-			if id, ok := dataSet[0].(float64); ok {
-				w.ChannelID = id
-			}
-			if book, ok := dataSet[1].([]interface{}); ok {
-				data := make(map[string]interface{})
-				data["snapshot"] = book
-				*mainCh <- data
-				return nil
-			} else {
-				return errors.New("fail to initial orderbook")
-			}
+// HandleDVFFrame passes the message of a frame to the book, gives up when ctx is done.
+func (w *DVFWebsocket) HandleDVFFrame(ctx context.Context, frame *DVFFrame, mainCh *chan *BookMessage) error {
+	message, err := w.BookMessage(frame)
+	if err != nil || message == nil {
+		return err
+	}
+	select {
+	case *mainCh <- message:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+// BookMessage turns a book frame into a message for the book, nil for events and unknown frames.
+func (w *DVFWebsocket) BookMessage(frame *DVFFrame) (*BookMessage, error) {
+	if frame.Event != nil {
+		return nil, w.HandleDVFEvent(frame.Event)
+	}
+	if w.ChannelID == 0 {
+		w.ChannelID = frame.ChanID
+	}
+	if frame.ChanID != w.ChannelID {
+		return nil, errors.New("wrong channel id return")
+	}
+	return frameMessage(frame, w.received, w.Logger)
+}
+
+// message of a data frame already known to belong to the book
+func frameMessage(frame *DVFFrame, received time.Time, logger *log.Logger) (*BookMessage, error) {
+	message := &BookMessage{Received: received}
+	switch frame.Type {
+	case "hb":
+		message.Kind = MessageHeartbeat
+	case "cs":
+		message.Kind = MessageChecksum
+		message.Checksum = frame.Checksum
+	case "":
+		entries, err := frame.BookEntries()
+		if err != nil {
+			return nil, fmt.Errorf("fail to decode orderbook: %w", err)
 		}
-		// update part
-		if id, ok := dataSet[0].(float64); ok {
-			if id != w.ChannelID {
-				return errors.New("wrong channel id return")
-			}
-			if book, ok := dataSet[1].([]interface{}); ok {
-				data := make(map[string]interface{})
-				data["update"] = book
-				*mainCh <- data
-				return nil
-			} else {
-				return errors.New("fail to update orderbook")
-			}
+		message.Entries = entries
+		message.Kind = MessageUpdate
+		if frame.Snapshot {
+			// initial orderbook
+			message.Kind = MessageSnapshot
 		}
+	default:
+		logger.Warningf("unknown DVF frame type %s", frame.Type)
+		return nil, nil
+	}
+	return message, nil
+}
+
+// info codes sent by the server
+const (
+	DVFInfoReconnect        = 20051
+	DVFInfoMaintenanceStart = 20060
+	DVFInfoMaintenanceEnd   = 20061
+)
 
+// DVFEventError is an error event sent by the server, or an info event asking to reconnect.
+type DVFEventError struct {
+	Event string
+	Code  int
+	Msg   string
+}
+
+func (e *DVFEventError) Error() string {
+	return fmt.Sprintf("DVF %s event, code %d: %s", e.Event, e.Code, e.Msg)
+}
+
+func (w *DVFWebsocket) HandleDVFEvent(event *DVFEvent) error {
+	switch event.Event {
+	case "subscribed":
+		w.ChannelID = event.ChanID
+		w.Logger.Infof("DVF subscribed to %s %s%s, channel id %v", event.Channel, event.Symbol, event.Key, event.ChanID)
+	case "unsubscribed":
+		w.Logger.Infof("DVF unsubscribed channel id %v", event.ChanID)
+	case "info":
+		switch event.Code {
+		case 0:
+			w.Logger.Infof("DVF socket info, version %d", event.Version)
+		case DVFInfoMaintenanceStart:
+			w.Logger.Warningf("DVF maintenance started: %s", event.Msg)
+		case DVFInfoReconnect, DVFInfoMaintenanceEnd:
+			return &DVFEventError{Event: event.Event, Code: event.Code, Msg: event.Msg}
+		default:
+			w.Logger.Infof("DVF socket info %d: %s", event.Code, event.Msg)
+		}
+	case "conf":
+		if event.Status != "OK" {
+			w.Logger.Warningf("DVF conf not accepted: %+v", *event)
+		}
+	case "error":
+		return &DVFEventError{Event: event.Event, Code: event.Code, Msg: event.Msg}
+	default:
+		w.Logger.Warningf("unknown DVF event %+v", *event)
 	}
 	return nil
 }
@@ -545,6 +742,46 @@ func GetDVFSubscribeMessage(channel, symbol string) (message []byte) {
 			return nil
 		}
 		message = by
+	case "trades", "ticker":
+		sub := DVFSubscribeMessage{
+			Event:   "subscribe",
+			Channel: channel,
+			Symbol:  symbol,
+		}
+		by, err := json.Marshal(sub)
+		if err != nil {
+			return nil
+		}
+		message = by
 	}
 	return message
 }
+
+func GetDVFBookSubscribeMessage(symbol string, opt BookOptions) (message []byte) {
+	sub := DVFSubscribeMessage{
+		Event:   "subscribe",
+		Channel: "book",
+		Symbol:  symbol,
+		Prec:    opt.Precision,
+		Freq:    opt.Frequency,
+		Len:     opt.lengthString(),
+	}
+	by, err := json.Marshal(sub)
+	if err != nil {
+		return nil
+	}
+	return by
+}
+
+type DVFUnsubscribeMessage struct {
+	Event  string  `json:"event"`
+	ChanID float64 `json:"chanId"`
+}
+
+func GetDVFUnsubscribeMessage(chanId float64) []byte {
+	by, err := json.Marshal(DVFUnsubscribeMessage{Event: "unsubscribe", ChanID: chanId})
+	if err != nil {
+		return nil
+	}
+	return by
+}
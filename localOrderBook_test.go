@@ -0,0 +1,189 @@
+package dvfapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+	"go.uber.org/goleak"
+)
+
+// the [][]string side the book used before the price levels, kept as a baseline
+func sliceBookSet(book [][]string, price, qty decimal.Decimal) [][]string {
+	for level, item := range book {
+		bookPrice, _ := decimal.NewFromString(item[0])
+		switch {
+		case price.GreaterThan(bookPrice):
+			if qty.IsZero() {
+				return book
+			}
+			book = append(book, []string{})
+			copy(book[level+1:], book[level:])
+			book[level] = []string{price.String(), qty.String()}
+			return book
+		case price.Equal(bookPrice):
+			if qty.IsZero() {
+				return append(book[:level], book[level+1:]...)
+			}
+			book[level][1] = qty.String()
+			return book
+		}
+	}
+	if qty.IsZero() {
+		return book
+	}
+	return append(book, []string{price.String(), qty.String()})
+}
+
+type levelUpdate struct {
+	price, qty decimal.Decimal
+}
+
+// updates spread over the whole depth, one in four removes its level
+func deepBookUpdates(depth, n int) []levelUpdate {
+	r := rand.New(rand.NewSource(1))
+	updates := make([]levelUpdate, n)
+	for i := range updates {
+		price := decimal.New(int64(100000-r.Intn(depth)), -2)
+		qty := decimal.New(int64(r.Intn(1000)), -3)
+		if r.Intn(4) == 0 {
+			qty = decimal.Zero
+		}
+		updates[i] = levelUpdate{price: price, qty: qty}
+	}
+	return updates
+}
+
+func BenchmarkDealWithBidPriceLevel(b *testing.B) {
+	for _, depth := range []int{100, 1000, 10000} {
+		updates := deepBookUpdates(depth, 4096)
+		b.Run(fmt.Sprintf("levels/%d", depth), func(b *testing.B) {
+			o := newOrderBookBranch(BookOptions{})
+			for i := 0; i < depth; i++ {
+				o.DealWithBidPriceLevel(decimal.New(int64(100000-i), -2), decimal.NewFromInt(1))
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				u := updates[i%len(updates)]
+				o.DealWithBidPriceLevel(u.price, u.qty)
+			}
+		})
+		b.Run(fmt.Sprintf("slice/%d", depth), func(b *testing.B) {
+			var book [][]string
+			for i := 0; i < depth; i++ {
+				book = sliceBookSet(book, decimal.New(int64(100000-i), -2), decimal.NewFromInt(1))
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				u := updates[i%len(updates)]
+				book = sliceBookSet(book, u.price, u.qty)
+			}
+		})
+	}
+}
+
+func quietLogger() *log.Logger {
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	return logger
+}
+
+// answers the subscription with a snapshot and one update, then keeps the connection open
+func bookServer(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		for _, frame := range []string{
+			`{"event":"subscribed","channel":"book","chanId":5,"symbol":"ETH:USDT"}`,
+			`[5,[[100,1,2],[101,1,-3]]]`,
+			`[5,[99,1,4]]`,
+		} {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+				return
+			}
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestCloseStopsGoroutines(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	server := bookServer(t)
+	defer server.Close()
+
+	o, err := localOrderBook("ws"+strings.TrimPrefix(server.URL, "http"), "ETH:USDT", quietLogger(), BookOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// never read, reset and snapshot fill it and the update after them blocks the book
+	blocked := o.Subscribe(2, Block)
+	var seen int32
+	o.OnEvent(func(BookEvent) { atomic.AddInt32(&seen, 1) }, 10, DropNewest)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(blocked.C) < cap(blocked.C) {
+		if time.Now().After(deadline) {
+			t.Fatal("no snapshot from the test server")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for range blocked.C {
+	}
+	// Close waited for the OnEvent goroutine
+	if atomic.LoadInt32(&seen) == 0 {
+		t.Fatal("OnEvent got no event")
+	}
+	if state := o.Status().State; state != Closed {
+		t.Fatalf("state %s after close", state)
+	}
+}
+
+func TestCloseReleasesBlockedSubscriber(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	o, err := NewOfflineOrderBook(BookOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// reset and snapshot fill the buffer, the reset of Close would block
+	sub := o.Subscribe(2, Block)
+	o.ApplyMessage(&BookMessage{Kind: MessageSnapshot, Entries: []BookEntry{{100, 1, 2}, {101, 1, -3}}})
+
+	closed := make(chan error, 1)
+	go func() { closed <- o.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked on a subscriber that stopped reading")
+	}
+	for range sub.C {
+	}
+	if late := o.Subscribe(1, Block); late != nil {
+		if _, ok := <-late.C; ok {
+			t.Fatal("subscription after close is open")
+		}
+	}
+}
@@ -2,6 +2,11 @@ package dvfapi
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
@@ -9,10 +14,12 @@ import (
 
 type GetOrderResponse struct {
 	ID          string  `json:"_id"`
+	Cid         string  `json:"cid"`
+	Type        string  `json:"type"`
 	Symbol      string  `json:"symbol"`
 	Amount      float64 `json:"amount"`
-	Price       int     `json:"price"`
-	TotalFilled int     `json:"totalFilled"`
+	Price       float64 `json:"price"`
+	TotalFilled float64 `json:"totalFilled"`
 	Pending     bool    `json:"pending"`
 	Canceled    bool    `json:"canceled"`
 	Active      bool    `json:"active"`
@@ -55,7 +62,7 @@ type GetAllOrdersResponse []struct {
 	Symbol       string    `json:"symbol"`
 	Amount       float64   `json:"amount"`
 	TotalFilled  float64   `json:"totalFilled"`
-	Price        int       `json:"price"`
+	Price        float64   `json:"price"`
 	AveragePrice float64   `json:"averagePrice"`
 	FeeRate      string    `json:"feeRate"`
 	TokenBuy     string    `json:"tokenBuy"`
@@ -75,6 +82,11 @@ func (p *Client) GetAllOrders(base, quote string) (result *GetAllOrdersResponse,
 	buffer.WriteString(base)
 	buffer.WriteString(":")
 	buffer.WriteString(quote)
+	return p.openOrders(buffer.String())
+}
+
+// empty symbol lists the open orders of every market
+func (p *Client) openOrders(symbol string) (result *GetAllOrdersResponse, err error) {
 	nonce := time.Now().Add(time.Second).Unix()
 	nonceStr := strconv.FormatInt(nonce, 10)
 	s, err := p.sign(nonceStr)
@@ -84,7 +96,9 @@ func (p *Client) GetAllOrders(base, quote string) (result *GetAllOrdersResponse,
 	params := make(map[string]string)
 	params["nonce"] = nonceStr
 	params["signature"] = s
-	params["symbol"] = buffer.String()
+	if symbol != "" {
+		params["symbol"] = symbol
+	}
 	jsonBody, err := json.Marshal(params)
 	if err != nil {
 		return nil, err
@@ -139,57 +153,365 @@ func (p *Client) CancelOrder(orderId string) (result *CancelOrderResponse, err e
 	return result, nil
 }
 
+// OrderRequest describes an order to submit. Amount is positive for buy and negative for sell.
+// Meta carries the StarkEx part of the order (starkOrder, starkMessage, ethAddress, starkPublicKey, starkSignature),
+// it is filled by the client's OrderSigner when left empty.
+type OrderRequest struct {
+	Cid         string
+	Type        string
+	Symbol      string
+	Amount      float64
+	Price       float64
+	TimeInForce TimeInForce
+	// only used with GTT, orders without it expire after DefaultOrderExpiry
+	ExpireAt time.Time
+	Meta     map[string]interface{}
+}
+
+type TimeInForce string
+
+const (
+	GTC      TimeInForce = "GTC"
+	GTT      TimeInForce = "GTT"
+	IOC      TimeInForce = "IOC"
+	PostOnly TimeInForce = "POST_ONLY"
+)
+
+// time in force the venue handles by itself, the others are emulated by the client
+var nativeTimeInForce = map[TimeInForce]bool{
+	GTC:      true,
+	GTT:      true,
+	PostOnly: true,
+	IOC:      false,
+}
+
+// StarkEx orders always carry an expiration, this one is used when the order does not set its own.
+var DefaultOrderExpiry = 28 * 24 * time.Hour
+
+// StarkEx expresses the expiration in hours since epoch, rounded up.
+func StarkExpiration(t time.Time) int64 {
+	return int64(math.Ceil(float64(t.Unix()) / 3600))
+}
+
+// Expiration of the order in StarkEx hours, to be put in starkOrder.expirationTimestamp by the signer.
+func (o *OrderRequest) Expiration() int64 {
+	if o.TimeInForce == GTT && !o.ExpireAt.IsZero() {
+		return StarkExpiration(o.ExpireAt)
+	}
+	return StarkExpiration(time.Now().Add(DefaultOrderExpiry))
+}
+
+// the expiration is signed in meta.starkOrder, so it can only be checked, not changed
+func (o *OrderRequest) checkExpiration() error {
+	var meta struct {
+		StarkOrder struct {
+			ExpirationTimestamp interface{} `json:"expirationTimestamp"`
+		} `json:"starkOrder"`
+	}
+	data, err := json.Marshal(o.Meta)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return fmt.Errorf("fail to read order meta: %w", err)
+	}
+	var signed int64
+	switch value := meta.StarkOrder.ExpirationTimestamp.(type) {
+	case nil:
+		if o.TimeInForce == GTT {
+			return errors.New("GTT order meta has no starkOrder.expirationTimestamp")
+		}
+		return nil
+	case float64:
+		signed = int64(value)
+	case string:
+		signed, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad starkOrder.expirationTimestamp %q", value)
+		}
+	default:
+		return fmt.Errorf("bad starkOrder.expirationTimestamp %v", value)
+	}
+	if o.TimeInForce == GTT {
+		if want := o.Expiration(); signed != want {
+			return fmt.Errorf("signed expiration %d does not match GTT expiration %d", signed, want)
+		}
+		return nil
+	}
+	if signed <= StarkExpiration(time.Now()) {
+		return fmt.Errorf("signed expiration %d is already passed", signed)
+	}
+	return nil
+}
+
+func (o *OrderRequest) checkTimeInForce() error {
+	switch o.TimeInForce {
+	case "":
+		o.TimeInForce = GTC
+	case GTC, IOC, PostOnly:
+	case GTT:
+		if o.ExpireAt.IsZero() {
+			return errors.New("GTT order without expiration")
+		}
+		// StarkEx works in hours, so the expiration has to land at least in the next hour
+		if StarkExpiration(o.ExpireAt) <= StarkExpiration(time.Now()) {
+			return fmt.Errorf("GTT expiration %s is already passed in StarkEx hours", o.ExpireAt)
+		}
+	default:
+		return fmt.Errorf("unknown time in force %s", o.TimeInForce)
+	}
+	return nil
+}
+
+// OrderSigner builds the StarkEx meta of an order before it is submitted.
+type OrderSigner func(order *OrderRequest) error
+
+// CancelAllOrders cancels every open order of the account, whatever the market.
+func (p *Client) CancelAllOrders() ([]CancelOrdersResult, error) {
+	orders, err := p.openOrders("")
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	if orders != nil {
+		for _, order := range *orders {
+			ids = append(ids, order.ID)
+		}
+	}
+	return p.CancelOrders(ids), nil
+}
+
 type SubmitOrderResponse struct {
+	ID          string  `json:"_id"`
+	Cid         string  `json:"cid"`
+	Type        string  `json:"type"`
+	Symbol      string  `json:"symbol"`
+	Amount      float64 `json:"amount"`
+	Price       float64 `json:"price"`
+	TotalFilled float64 `json:"totalFilled"`
+	Pending     bool    `json:"pending"`
+	Canceled    bool    `json:"canceled"`
+	Active      bool    `json:"active"`
+}
+
+// This endpoint allows to submit a new order, the StarkEx meta has to be signed by the OrderSigner or provided by the caller.
+// IOC is not supported by the venue, it is emulated by cancelling whatever is left right after the submission.
+func (p *Client) SubmitOrder(order *OrderRequest) (result *SubmitOrderResponse, err error) {
+	if err := order.checkTimeInForce(); err != nil {
+		return nil, err
+	}
+	if order.Cid == "" {
+		order.Cid = newCid()
+	}
+	if order.Type == "" {
+		order.Type = "EXCHANGE LIMIT"
+	}
+	if order.Meta == nil {
+		if p.OrderSigner == nil {
+			return nil, errors.New("order meta is empty and no order signer is set")
+		}
+		if err := p.OrderSigner(order); err != nil {
+			return nil, err
+		}
+	}
+	if err := order.checkExpiration(); err != nil {
+		return nil, err
+	}
+	nonce := time.Now().Add(time.Second).Unix()
+	nonceStr := strconv.FormatInt(nonce, 10)
+	s, err := p.sign(nonceStr)
+	if err != nil {
+		return nil, err
+	}
+	params := make(map[string]interface{})
+	params["cid"] = order.Cid
+	params["type"] = order.Type
+	params["symbol"] = order.Symbol
+	params["amount"] = strconv.FormatFloat(order.Amount, 'f', -1, 64)
+	params["price"] = strconv.FormatFloat(order.Price, 'f', -1, 64)
+	params["meta"] = order.Meta
+	if order.TimeInForce == PostOnly {
+		params["isPostOnly"] = true
+	}
+	params["nonce"] = nonceStr
+	params["signature"] = s
+
+	jsonBody, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := p.sendRequest(http.MethodPost, "/v1/trading/w/submitOrder", jsonBody, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = decode(res, &result)
+	if err != nil {
+		return nil, err
+	}
+	if !nativeTimeInForce[order.TimeInForce] {
+		return p.cancelRemainder(result)
+	}
+	return result, nil
+}
+
+// emulate IOC, cancel the order and report the state it was closed with
+func (p *Client) cancelRemainder(submitted *SubmitOrderResponse) (*SubmitOrderResponse, error) {
+	if !submitted.Active && !submitted.Pending {
+		return submitted, nil
+	}
+	var closed *GetOrderResponse
+	if _, err := p.CancelOrder(submitted.ID); err != nil {
+		// the order may have filled in full before the cancel
+		orders, lookupErr := p.GetOrder(submitted.ID)
+		if lookupErr != nil || len(orders) == 0 || orders[0].Active || orders[0].Pending {
+			return submitted, fmt.Errorf("order %s submitted but remainder not canceled: %w", submitted.ID, err)
+		}
+		closed = orders[0]
+	} else {
+		closed, err = p.waitOrderClosed(submitted.ID, 10*time.Second)
+		if err != nil {
+			return submitted, fmt.Errorf("order %s submitted but remainder not confirmed canceled: %w", submitted.ID, err)
+		}
+	}
+	submitted.TotalFilled = closed.TotalFilled
+	submitted.Active = closed.Active
+	submitted.Pending = closed.Pending
+	submitted.Canceled = closed.Canceled
+	return submitted, nil
 }
 
-// func (p *Client) SubmitOrder() (result *SubmitOrderResponse, err error) {
-// 	s, err := p.sign()
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	params := make(map[string]interface{})
-// 	meta := make(map[string]interface{})
-// 	starkOrder := make(map[string]interface{})
-
-// 	nonce := time.Now().Unix()
-
-// 	params["cid"] = ""
-// 	params["type"] = ""
-// 	params["symbol"] = ""
-// 	params["amount"] = ""
-// 	params["price"] = ""
-
-// 	starkOrder["vaultIdSell"] = ""
-// 	starkOrder["vaultIdBuy"] = ""
-// 	starkOrder["amountSell"] = ""
-// 	starkOrder["amountBuy"] = ""
-// 	starkOrder["tokenSell"] = ""
-// 	starkOrder["tokenBuy"] = ""
-// 	starkOrder["nonce"] = strconv.FormatInt(nonce, 10)
-// 	starkOrder["expirationTimestamp"] = ""
-
-// 	meta["starkOrder"] = starkOrder
-// 	meta["starkMessage"] = ""
-// 	meta["ethAddress"] = ""
-// 	meta["starkPublicKey"] = ""
-// 	meta["starkSignature"] = ""
-
-// 	params["nonce"] = strconv.FormatInt(nonce, 10)
-// 	params["signature"] = s
-
-// 	jsonBody, err := json.Marshal(params)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	res, err := p.sendRequest(http.MethodPost, "/v1/trading/w/cancelOrder", jsonBody, nil)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	err = decode(res, &result)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	return result, nil
-// }
+type ReplaceStep struct {
+	Step   string
+	Time   time.Time
+	Detail string
+}
+
+type ReplaceOrderResult struct {
+	OrderId string
+	// response of the cancel request
+	Cancel *CancelOrderResponse
+	// the old order is confirmed closed
+	Canceled bool
+	// the old order was already closed, filled or canceled elsewhere, when the cancel failed
+	AlreadyClosed bool
+	// total filled on the old order once it was closed
+	Filled float64
+	// signed amount sent with the replacement, zero if nothing was left to replace
+	Remaining float64
+	Cid       string
+	Submitted *SubmitOrderResponse
+	Steps     []ReplaceStep
+}
+
+func (r *ReplaceOrderResult) record(step, detail string) {
+	r.Steps = append(r.Steps, ReplaceStep{Step: step, Time: time.Now(), Detail: detail})
+}
+
+// ReplaceOrder cancels the order, waits for the cancel to be confirmed and for the last fills,
+// then submits a replacement with a new cid. newAmount is the total size wanted for the order,
+// the fills of the old order are taken off it before the replacement is sent.
+func (p *Client) ReplaceOrder(orderId string, newPrice, newAmount float64) (result *ReplaceOrderResult, err error) {
+	result = &ReplaceOrderResult{OrderId: orderId}
+	orders, err := p.GetOrder(orderId)
+	if err != nil {
+		result.record("lookup", err.Error())
+		return result, err
+	}
+	if len(orders) == 0 {
+		err = fmt.Errorf("order %s not found", orderId)
+		result.record("lookup", err.Error())
+		return result, err
+	}
+	old := orders[0]
+	result.record("lookup", fmt.Sprintf("symbol %s amount %v filled %v", old.Symbol, old.Amount, old.TotalFilled))
+	// the replacement is signed after the cancel, without a signer the old order would be gone for nothing
+	if p.OrderSigner == nil {
+		err = errors.New("no order signer is set to sign the replacement")
+		result.record("submit", err.Error())
+		return result, err
+	}
+
+	var closed *GetOrderResponse
+	cancel, err := p.CancelOrder(orderId)
+	if err != nil {
+		result.record("cancel", err.Error())
+		// the order may have filled or been canceled elsewhere just before the cancel
+		orders, lookupErr := p.GetOrder(orderId)
+		if lookupErr != nil {
+			result.record("confirm", lookupErr.Error())
+			return result, err
+		}
+		if len(orders) == 0 || orders[0].Active || orders[0].Pending {
+			return result, err
+		}
+		closed = orders[0]
+		result.AlreadyClosed = true
+		result.Filled = closed.TotalFilled
+		result.record("confirm", fmt.Sprintf("order already closed, filled %v", closed.TotalFilled))
+	} else {
+		result.Cancel = cancel
+		result.record("cancel", fmt.Sprintf("cancel sent, canceled %v", cancel.Canceled))
+		closed, err = p.waitOrderClosed(orderId, 10*time.Second)
+		if err != nil {
+			result.record("confirm", err.Error())
+			return result, err
+		}
+		result.Canceled = true
+		result.Filled = closed.TotalFilled
+		result.record("confirm", fmt.Sprintf("order closed, filled %v", closed.TotalFilled))
+	}
+
+	remaining := math.Abs(newAmount) - math.Abs(closed.TotalFilled)
+	if remaining <= 0 {
+		result.record("submit", "nothing left to replace")
+		return result, nil
+	}
+	if newAmount < 0 {
+		remaining = -remaining
+	}
+	result.Remaining = remaining
+	order := OrderRequest{
+		Cid:    newCid(),
+		Type:   closed.Type,
+		Symbol: closed.Symbol,
+		Amount: remaining,
+		Price:  newPrice,
+	}
+	result.Cid = order.Cid
+	submitted, err := p.SubmitOrder(&order)
+	if err != nil {
+		result.record("submit", err.Error())
+		return result, err
+	}
+	result.Submitted = submitted
+	result.record("submit", fmt.Sprintf("replacement %s submitted, amount %v price %v", submitted.ID, remaining, newPrice))
+	return result, nil
+}
+
+// poll the order until it is no longer active nor pending
+func (p *Client) waitOrderClosed(orderId string, timeout time.Duration) (*GetOrderResponse, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		orders, err := p.GetOrder(orderId)
+		if err != nil {
+			return nil, err
+		}
+		if len(orders) != 0 && !orders[0].Active && !orders[0].Pending {
+			return orders[0], nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("order %s still open after %s", orderId, timeout)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func newCid() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+	return strconv.FormatInt(time.Now().Unix(), 10) + hex.EncodeToString(b)
+}
@@ -0,0 +1,138 @@
+package dvfapi
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const maxSkipLevel = 24
+
+type priceLevel struct {
+	price decimal.Decimal
+	qty   decimal.Decimal
+	next  []*priceLevel
+}
+
+// priceLevels is a skip list of price levels, best price first.
+// Bids are kept in descending price order and asks in ascending price order.
+type priceLevels struct {
+	desc   bool
+	head   *priceLevel
+	level  int
+	length int
+	rnd    *rand.Rand
+	update [maxSkipLevel]*priceLevel
+}
+
+func newPriceLevels(desc bool) *priceLevels {
+	return &priceLevels{
+		desc:  desc,
+		head:  &priceLevel{next: make([]*priceLevel, maxSkipLevel)},
+		level: 1,
+		rnd:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// a sorts before b
+func (l *priceLevels) before(a, b decimal.Decimal) bool {
+	if l.desc {
+		return a.GreaterThan(b)
+	}
+	return a.LessThan(b)
+}
+
+func (l *priceLevels) randomLevel() int {
+	level := 1
+	for level < maxSkipLevel && l.rnd.Int63()&3 == 0 {
+		level++
+	}
+	return level
+}
+
+// set the size of the price level, zero qty removes it. Returns false if nothing changed.
+func (l *priceLevels) set(price, qty decimal.Decimal) bool {
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && l.before(x.next[i].price, price) {
+			x = x.next[i]
+		}
+		l.update[i] = x
+	}
+	x = x.next[0]
+	if x != nil && x.price.Equal(price) {
+		if qty.IsZero() {
+			for i := 0; i < len(x.next); i++ {
+				l.update[i].next[i] = x.next[i]
+			}
+			for l.level > 1 && l.head.next[l.level-1] == nil {
+				l.level--
+			}
+			l.length--
+			return true
+		}
+		if x.qty.Equal(qty) {
+			return false
+		}
+		x.qty = qty
+		return true
+	}
+	if qty.IsZero() {
+		return false
+	}
+	level := l.randomLevel()
+	if level > l.level {
+		for i := l.level; i < level; i++ {
+			l.update[i] = l.head
+		}
+		l.level = level
+	}
+	x = &priceLevel{price: price, qty: qty, next: make([]*priceLevel, level)}
+	for i := 0; i < level; i++ {
+		x.next[i] = l.update[i].next[i]
+		l.update[i].next[i] = x
+	}
+	l.length++
+	return true
+}
+
+func (l *priceLevels) get(price decimal.Decimal) (decimal.Decimal, bool) {
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && l.before(x.next[i].price, price) {
+			x = x.next[i]
+		}
+	}
+	x = x.next[0]
+	if x != nil && x.price.Equal(price) {
+		return x.qty, true
+	}
+	return decimal.Zero, false
+}
+
+// best level, nil if empty
+func (l *priceLevels) first() *priceLevel {
+	return l.head.next[0]
+}
+
+// walk the levels from the best price, stop when fn returns false
+func (l *priceLevels) each(fn func(price, qty decimal.Decimal) bool) {
+	for x := l.head.next[0]; x != nil; x = x.next[0] {
+		if !fn(x.price, x.qty) {
+			return
+		}
+	}
+}
+
+func (l *priceLevels) len() int {
+	return l.length
+}
+
+func (l *priceLevels) clear() {
+	for i := range l.head.next {
+		l.head.next[i] = nil
+	}
+	l.level = 1
+	l.length = 0
+}
@@ -0,0 +1,237 @@
+package dvfapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+type OrderUpdate struct {
+	ID          string    `json:"_id"`
+	Cid         string    `json:"cid"`
+	Symbol      string    `json:"symbol"`
+	Type        string    `json:"type"`
+	Amount      float64   `json:"amount"`
+	Price       float64   `json:"price"`
+	TotalFilled float64   `json:"totalFilled"`
+	Active      bool      `json:"active"`
+	Pending     bool      `json:"pending"`
+	Canceled    bool      `json:"canceled"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type Fill struct {
+	ID        string    `json:"_id"`
+	OrderID   string    `json:"orderId"`
+	Symbol    string    `json:"symbol"`
+	Amount    float64   `json:"amount"`
+	Price     float64   `json:"price"`
+	Fee       float64   `json:"fee"`
+	FeeToken  string    `json:"feeToken"`
+	Maker     bool      `json:"maker"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type BalanceUpdate struct {
+	Token         string `json:"token"`
+	Balance       int64  `json:"balance"`
+	ActiveBalance int64  `json:"activeBalance"`
+}
+
+// PrivateHandlers are called from the stream goroutine, they should not block.
+type PrivateHandlers struct {
+	OnOrder   func(update OrderUpdate)
+	OnFill    func(fill Fill)
+	OnBalance func(update BalanceUpdate)
+	// errors of the stream, the stream reconnects by itself
+	OnError func(err error)
+}
+
+type privateMessage struct {
+	Event  string  `json:"event"`
+	Status string  `json:"status"`
+	Code   int     `json:"code"`
+	Msg    string  `json:"msg"`
+	Type   string  `json:"type"`
+	Data   rawJSON `json:"data"`
+}
+
+type privateAuthMessage struct {
+	Event     string `json:"event"`
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+}
+
+// PrivateStream is the authenticated socket of the account, it signs in with the same nonce and signature as the REST calls.
+// After a disconnect it reconnects with backoff and signs in again.
+type PrivateStream struct {
+	client   *Client
+	url      string
+	logger   *log.Logger
+	handlers PrivateHandlers
+	retry    *backoff
+	cancel   context.CancelFunc
+
+	mux    sync.RWMutex
+	authed bool
+}
+
+func (p *Client) PrivateStream(handlers PrivateHandlers, logger *log.Logger) *PrivateStream {
+	return p.PrivateStreamWithPolicy(handlers, logger, DefaultReconnectPolicy())
+}
+
+func (p *Client) PrivateStreamWithPolicy(handlers PrivateHandlers, logger *log.Logger, policy ReconnectPolicy) *PrivateStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &PrivateStream{
+		client:   p,
+		url:      SocketEndPointHub(true),
+		logger:   logger,
+		handlers: handlers,
+		retry:    newBackoff(&policy),
+		cancel:   cancel,
+	}
+	go keepConnected(ctx, "private", s.retry, logger, func(ctx context.Context) error {
+		err := s.run(ctx)
+		s.setAuthed(false)
+		if err != nil {
+			s.onError(err)
+		}
+		return err
+	})
+	return s
+}
+
+// signed in on the current connection
+func (s *PrivateStream) Authenticated() bool {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.authed
+}
+
+func (s *PrivateStream) Close() {
+	s.cancel()
+}
+
+func (s *PrivateStream) setAuthed(authed bool) {
+	s.mux.Lock()
+	s.authed = authed
+	s.mux.Unlock()
+}
+
+func (s *PrivateStream) onError(err error) {
+	if s.handlers.OnError != nil {
+		s.handlers.OnError(err)
+	}
+}
+
+func (s *PrivateStream) authMessage() ([]byte, error) {
+	nonce := time.Now().Add(time.Second).Unix()
+	nonceStr := strconv.FormatInt(nonce, 10)
+	signature, err := s.client.sign(nonceStr)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(privateAuthMessage{Event: "auth", Nonce: nonceStr, Signature: signature})
+}
+
+// signs in on a new connection and handles the account events until it breaks
+func (s *PrivateStream) run(ctx context.Context) error {
+	if s.url == "" {
+		return errors.New("no private socket endpoint")
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(s.url, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	auth, err := s.authMessage()
+	if err != nil {
+		return err
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, auth); err != nil {
+		return err
+	}
+	frames, stop := readFrames(conn)
+	defer stop()
+	ping := time.NewTicker(socketPingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ping.C:
+			if err := sendPing(conn); err != nil {
+				return err
+			}
+		case frame, ok := <-frames:
+			if !ok {
+				return errors.New("socket reader stopped")
+			}
+			if frame.err != nil {
+				return frame.err
+			}
+			if err := s.handle(frame.data); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *PrivateStream) handle(data []byte) error {
+	var message privateMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		// a bad message is reported, the stream goes on
+		s.onError(fmt.Errorf("fail to decode private message: %w", err))
+		return nil
+	}
+	switch message.Event {
+	case "":
+	case "auth":
+		if message.Status != "OK" {
+			return &DVFEventError{Event: message.Event, Code: message.Code, Msg: message.Msg}
+		}
+		s.setAuthed(true)
+		s.retry.reset()
+		s.logger.Infoln("DVF private socket authenticated.")
+		return nil
+	case "error":
+		return &DVFEventError{Event: message.Event, Code: message.Code, Msg: message.Msg}
+	case "info":
+		if message.Code == DVFInfoReconnect || message.Code == DVFInfoMaintenanceEnd {
+			return &DVFEventError{Event: message.Event, Code: message.Code, Msg: message.Msg}
+		}
+		return nil
+	default:
+		return nil
+	}
+	var err error
+	switch message.Type {
+	case "orderUpdate":
+		var update OrderUpdate
+		if err = json.Unmarshal(message.Data, &update); err == nil && s.handlers.OnOrder != nil {
+			s.handlers.OnOrder(update)
+		}
+	case "fill":
+		var fill Fill
+		if err = json.Unmarshal(message.Data, &fill); err == nil && s.handlers.OnFill != nil {
+			s.handlers.OnFill(fill)
+		}
+	case "balanceUpdate":
+		var update BalanceUpdate
+		if err = json.Unmarshal(message.Data, &update); err == nil && s.handlers.OnBalance != nil {
+			s.handlers.OnBalance(update)
+		}
+	default:
+		s.logger.Debugf("DVF private message type %s ignored", message.Type)
+	}
+	if err != nil {
+		s.onError(fmt.Errorf("fail to decode private %s: %w", message.Type, err))
+	}
+	return nil
+}
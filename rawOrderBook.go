@@ -0,0 +1,243 @@
+package dvfapi
+
+import (
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// rawBook tracks the individual orders of an R0 subscription, in arrival order per price level.
+type rawBook struct {
+	mux    sync.RWMutex
+	orders map[string]*rawOrder
+	// orders of a level, front of the queue first
+	queues map[rawLevelKey][]*rawOrder
+}
+
+type rawLevelKey struct {
+	bid   bool
+	price string
+}
+
+type rawOrder struct {
+	id     string
+	price  decimal.Decimal
+	amount decimal.Decimal
+	bid    bool
+}
+
+type RawOrder struct {
+	ID     string
+	Price  decimal.Decimal
+	Amount decimal.Decimal
+	Bid    bool
+}
+
+// QueuePosition is where an order sits in its price level.
+type QueuePosition struct {
+	Price decimal.Decimal
+	Bid   bool
+	// orders and amount in front of it
+	OrdersAhead int
+	AmountAhead decimal.Decimal
+	// whole level, the order included
+	LevelOrders int
+	LevelAmount decimal.Decimal
+}
+
+func newRawBook() *rawBook {
+	return &rawBook{
+		orders: make(map[string]*rawOrder),
+		queues: make(map[rawLevelKey][]*rawOrder),
+	}
+}
+
+func (r *rawBook) reset() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.orders = make(map[string]*rawOrder)
+	r.queues = make(map[rawLevelKey][]*rawOrder)
+}
+
+// caller holds mux
+func (r *rawBook) levelAmount(key rawLevelKey) decimal.Decimal {
+	total := decimal.Zero
+	for _, order := range r.queues[key] {
+		total = total.Add(order.amount)
+	}
+	return total
+}
+
+// caller holds mux
+func (r *rawBook) remove(order *rawOrder) rawLevelKey {
+	key := rawLevelKey{bid: order.bid, price: order.price.String()}
+	queue := r.queues[key]
+	for i, item := range queue {
+		if item == order {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) == 0 {
+		delete(r.queues, key)
+	} else {
+		r.queues[key] = queue
+	}
+	delete(r.orders, order.id)
+	return key
+}
+
+// apply adds, modifies or removes an order, a zero price removes it.
+// A modified order keeps its place if it stays on the same price and does not grow, otherwise it goes to the back.
+// Returns the levels that changed with their new total amount.
+func (r *rawBook) apply(id string, price, amount decimal.Decimal) map[rawLevelKey]decimal.Decimal {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	changed := make(map[rawLevelKey]decimal.Decimal)
+	old, exist := r.orders[id]
+	if price.IsZero() {
+		if exist {
+			key := r.remove(old)
+			changed[key] = r.levelAmount(key)
+		}
+		return changed
+	}
+	bid := amount.IsPositive()
+	size := amount.Abs()
+	key := rawLevelKey{bid: bid, price: price.String()}
+	if exist && old.bid == bid && old.price.Equal(price) && size.LessThanOrEqual(old.amount) {
+		old.amount = size
+		changed[key] = r.levelAmount(key)
+		return changed
+	}
+	if exist {
+		oldKey := r.remove(old)
+		changed[oldKey] = r.levelAmount(oldKey)
+	}
+	order := &rawOrder{id: id, price: price, amount: size, bid: bid}
+	r.orders[id] = order
+	r.queues[key] = append(r.queues[key], order)
+	changed[key] = r.levelAmount(key)
+	return changed
+}
+
+// [order id, amount] of the first n orders in price then queue order, amount negative for asks.
+// The caller holds the mux of the side of levels.
+func (r *rawBook) topOrders(levels *priceLevels, bid bool, n int) [][2]string {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	top := make([][2]string, 0, n)
+	for x := levels.first(); x != nil && len(top) < n; x = x.next[0] {
+		for _, order := range r.queues[rawLevelKey{bid: bid, price: x.price.String()}] {
+			if len(top) == n {
+				break
+			}
+			amount := order.amount
+			if !bid {
+				amount = amount.Neg()
+			}
+			top = append(top, [2]string{order.id, checksumNumber(amount)})
+		}
+	}
+	return top
+}
+
+func (r *rawBook) position(order *rawOrder) QueuePosition {
+	key := rawLevelKey{bid: order.bid, price: order.price.String()}
+	pos := QueuePosition{
+		Price:       order.price,
+		Bid:         order.bid,
+		AmountAhead: decimal.Zero,
+		LevelAmount: decimal.Zero,
+	}
+	ahead := true
+	for _, item := range r.queues[key] {
+		if item == order {
+			ahead = false
+		}
+		if ahead {
+			pos.OrdersAhead++
+			pos.AmountAhead = pos.AmountAhead.Add(item.amount)
+		}
+		pos.LevelOrders++
+		pos.LevelAmount = pos.LevelAmount.Add(item.amount)
+	}
+	return pos
+}
+
+// raw entries are [orderId, price, amount], positive amount for bids
+func (o *OrderBookBranch) applyRawOrder(entry BookEntry) {
+	changed := o.raw.apply(rawOrderID(entry[0]), decimal.NewFromFloat(entry[1]), decimal.NewFromFloat(entry[2]))
+	for key, total := range changed {
+		levelPrice, _ := decimal.NewFromString(key.price)
+		if key.bid {
+			o.DealWithBidPriceLevel(levelPrice, total)
+		} else {
+			o.DealWithAskPriceLevel(levelPrice, total)
+		}
+	}
+}
+
+func (o *OrderBookBranch) InitialRawOrderBook(entries []BookEntry) {
+	o.resetBook()
+	for _, entry := range entries {
+		o.applyRawOrder(entry)
+	}
+	o.SnapShoted = true
+	o.setState(Live)
+	o.publishSnapshot()
+}
+
+func (o *OrderBookBranch) RawUpdateJudge(entry BookEntry) {
+	o.applyRawOrder(entry)
+}
+
+// IsRaw tells if the book tracks individual orders (R0 precision).
+func (o *OrderBookBranch) IsRaw() bool {
+	return o.raw != nil
+}
+
+// RawOrders returns the orders of a price level, front of the queue first. Only for raw books.
+func (o *OrderBookBranch) RawOrders(bid bool, price decimal.Decimal) []RawOrder {
+	if o.raw == nil {
+		return nil
+	}
+	o.raw.mux.RLock()
+	defer o.raw.mux.RUnlock()
+	queue := o.raw.queues[rawLevelKey{bid: bid, price: price.String()}]
+	orders := make([]RawOrder, 0, len(queue))
+	for _, item := range queue {
+		orders = append(orders, RawOrder{ID: item.id, Price: item.price, Amount: item.amount, Bid: item.bid})
+	}
+	return orders
+}
+
+// QueuePosition of an order of the book, false if the book is not raw or the order is unknown.
+func (o *OrderBookBranch) QueuePosition(orderId string) (QueuePosition, bool) {
+	if o.raw == nil {
+		return QueuePosition{}, false
+	}
+	o.raw.mux.RLock()
+	defer o.raw.mux.RUnlock()
+	order, ok := o.raw.orders[orderId]
+	if !ok {
+		return QueuePosition{}, false
+	}
+	return o.raw.position(order), true
+}
+
+// EstimateQueuePosition for an order joining the price level now, everything already resting there is ahead of it.
+func (o *OrderBookBranch) EstimateQueuePosition(bid bool, price decimal.Decimal) (QueuePosition, bool) {
+	if o.raw == nil {
+		return QueuePosition{}, false
+	}
+	o.raw.mux.RLock()
+	defer o.raw.mux.RUnlock()
+	key := rawLevelKey{bid: bid, price: price.String()}
+	pos := QueuePosition{Price: price, Bid: bid}
+	pos.OrdersAhead = len(o.raw.queues[key])
+	pos.AmountAhead = o.raw.levelAmount(key)
+	pos.LevelOrders = pos.OrdersAhead
+	pos.LevelAmount = pos.AmountAhead
+	return pos, true
+}
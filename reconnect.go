@@ -0,0 +1,149 @@
+package dvfapi
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ReconnectPolicy spaces out the reconnects of a websocket with exponential backoff and jitter.
+// After MaxFailures failures in a row the breaker opens and nothing is tried for Cooldown.
+type ReconnectPolicy struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	// fraction of the delay added or taken off at random, 0.2 means +-20%
+	Jitter float64
+	// 0 never opens the breaker
+	MaxFailures int
+	// wait once the breaker is open, 0 takes the one of DefaultReconnectPolicy
+	Cooldown time.Duration
+	// called before waiting for every reconnect
+	OnAttempt func(attempt ReconnectAttempt)
+}
+
+type ReconnectAttempt struct {
+	// failures in a row, this one included
+	Failures    int
+	Delay       time.Duration
+	Err         error
+	BreakerOpen bool
+}
+
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		Initial:     500 * time.Millisecond,
+		Max:         30 * time.Second,
+		Multiplier:  2,
+		Jitter:      0.2,
+		MaxFailures: 10,
+		Cooldown:    5 * time.Minute,
+	}
+}
+
+type backoff struct {
+	policy   ReconnectPolicy
+	mux      sync.Mutex
+	failures int
+	rnd      *rand.Rand
+}
+
+func newBackoff(policy *ReconnectPolicy) *backoff {
+	p := DefaultReconnectPolicy()
+	if policy != nil {
+		p = *policy
+	}
+	if p.Initial <= 0 {
+		p.Initial = 500 * time.Millisecond
+	}
+	if p.Max < p.Initial {
+		p.Max = p.Initial
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 1
+	}
+	if p.MaxFailures > 0 && p.Cooldown <= 0 {
+		// an open breaker without cooldown would reconnect at once
+		p.Cooldown = DefaultReconnectPolicy().Cooldown
+	}
+	return &backoff{
+		policy: p,
+		rnd:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// the connection worked, start over from the initial delay
+func (b *backoff) reset() {
+	b.mux.Lock()
+	b.failures = 0
+	b.mux.Unlock()
+}
+
+// records a failure and returns what to wait before the next try
+func (b *backoff) fail(err error) ReconnectAttempt {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.failures++
+	attempt := ReconnectAttempt{Failures: b.failures, Err: err}
+	if b.policy.MaxFailures > 0 && b.failures >= b.policy.MaxFailures {
+		attempt.BreakerOpen = true
+		attempt.Delay = b.policy.Cooldown
+		b.failures = 0
+	} else {
+		delay := float64(b.policy.Initial) * math.Pow(b.policy.Multiplier, float64(b.failures-1))
+		if delay > float64(b.policy.Max) {
+			delay = float64(b.policy.Max)
+		}
+		if b.policy.Jitter > 0 {
+			delay += delay * b.policy.Jitter * (2*b.rnd.Float64() - 1)
+		}
+		attempt.Delay = time.Duration(delay)
+	}
+	if b.policy.OnAttempt != nil {
+		b.policy.OnAttempt(attempt)
+	}
+	return attempt
+}
+
+// returns false if the context ended first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// keepConnected calls run until ctx is done or run returns nil, waiting with backoff after every error.
+// run holds one connection and returns when it breaks.
+func keepConnected(ctx context.Context, name string, retry *backoff, logger *log.Logger, run func(ctx context.Context) error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			err := run(ctx)
+			if err == nil {
+				return
+			}
+			logger.Warningf("DVF %s socket reconnect cause: %s", name, err.Error())
+			attempt := retry.fail(err)
+			if attempt.BreakerOpen {
+				logger.Errorf("DVF %s socket failed %d times in a row, waiting %s", name, attempt.Failures, attempt.Delay)
+			}
+			if !sleepContext(ctx, attempt.Delay) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package dvfapi
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// one line of a recording, t is the receive time in unix nanoseconds and f the frame as it came
+type recordedFrame struct {
+	T int64   `json:"t"`
+	F rawJSON `json:"f"`
+}
+
+// FrameRecorder writes socket frames with their receive time to a gzipped newline-delimited JSON file.
+type FrameRecorder struct {
+	mux  sync.Mutex
+	file *os.File
+	gz   *gzip.Writer
+	buf  *bufio.Writer
+}
+
+func NewFrameRecorder(path string) (*FrameRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(file)
+	return &FrameRecorder{
+		file: file,
+		gz:   gz,
+		buf:  bufio.NewWriter(gz),
+	}, nil
+}
+
+func (r *FrameRecorder) Record(received time.Time, frame []byte) error {
+	if !json.Valid(frame) {
+		return errors.New("frame is not valid json")
+	}
+	line, err := json.Marshal(recordedFrame{T: received.UnixNano(), F: frame})
+	if err != nil {
+		return err
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.file == nil {
+		return errors.New("recorder is closed")
+	}
+	if _, err := r.buf.Write(line); err != nil {
+		return err
+	}
+	return r.buf.WriteByte('\n')
+}
+
+// Close flushes the recording, the file is not readable before.
+func (r *FrameRecorder) Close() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.buf.Flush()
+	if gzErr := r.gz.Close(); err == nil {
+		err = gzErr
+	}
+	if fileErr := r.file.Close(); err == nil {
+		err = fileErr
+	}
+	r.file = nil
+	return err
+}
+
+// NewOfflineOrderBook makes a book without socket, to be fed by ReplayRecording or ApplyMessage.
+func NewOfflineOrderBook(opt BookOptions) (*OrderBookBranch, error) {
+	if err := opt.Validate(); err != nil {
+		return nil, err
+	}
+	o := newOrderBookBranch(opt)
+	_, cancel := context.WithCancel(context.Background())
+	o.Cancel = &cancel
+	return o, nil
+}
+
+// ReplayRecording feeds a recording made by FrameRecorder into the book.
+// Error and reconnect events of the recorded session are logged and skipped.
+// speed 1 replays at the recorded pace, 10 ten times faster, 0 or less as fast as possible.
+func ReplayRecording(ctx context.Context, path string, book *OrderBookBranch, speed float64, logger *log.Logger) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	w := DVFWebsocket{Logger: logger}
+	var first int64
+	start := time.Now()
+	line := 0
+	for scanner.Scan() {
+		line++
+		var rec recordedFrame
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if first == 0 {
+			first = rec.T
+		}
+		if speed > 0 {
+			due := start.Add(time.Duration(float64(rec.T-first) / speed))
+			if !sleepContext(ctx, time.Until(due)) {
+				return ctx.Err()
+			}
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		frame, err := DecodeDVFFrame(rec.F)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		w.received = time.Now()
+		message, err := w.BookMessage(frame)
+		var eventErr *DVFEventError
+		if errors.As(err, &eventErr) {
+			// reconnects and maintenance of the recorded session, the frames after them carry on
+			logger.Infof("replay line %d: %s", line, err.Error())
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		book.ApplyMessage(message)
+	}
+	return scanner.Err()
+}
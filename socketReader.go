@@ -0,0 +1,66 @@
+package dvfapi
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// the read deadline, pushed back by every message and pong
+	socketReadTimeout = 30 * time.Second
+	socketPingPeriod  = 10 * time.Second
+	socketWriteWait   = 5 * time.Second
+)
+
+type socketFrame struct {
+	data     []byte
+	received time.Time
+	err      error
+}
+
+// readFrames reads the connection from its own goroutine, blocking on the socket instead of polling it.
+// The channel gets the error of the last read and is closed after it.
+// stop closes the connection and returns once the reading goroutine is gone.
+func readFrames(conn *websocket.Conn) (frames <-chan socketFrame, stop func()) {
+	ch := make(chan socketFrame, 100)
+	done := make(chan struct{})
+	conn.SetReadDeadline(time.Now().Add(socketReadTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(socketReadTimeout))
+	})
+	go func() {
+		defer close(ch)
+		for {
+			_, buf, err := conn.ReadMessage()
+			frame := socketFrame{data: buf, received: time.Now(), err: err}
+			if err == nil {
+				conn.SetReadDeadline(time.Now().Add(socketReadTimeout))
+			}
+			select {
+			case ch <- frame:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			close(done)
+			conn.Close()
+			for range ch {
+			}
+		})
+	}
+	return ch, stop
+}
+
+// WriteControl is safe to call next to the other writes of the connection
+func sendPing(conn *websocket.Conn) error {
+	return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait))
+}
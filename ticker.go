@@ -0,0 +1,117 @@
+package dvfapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type Ticker struct {
+	Symbol              string
+	Bid                 float64
+	BidSize             float64
+	Ask                 float64
+	AskSize             float64
+	DailyChange         float64
+	DailyChangeRelative float64
+	LastPrice           float64
+	Volume              float64
+	High                float64
+	Low                 float64
+	// receive time for the socket ticker
+	Time time.Time
+}
+
+// ticker rows are [bid, bidSize, ask, askSize, dailyChange, dailyChangeRelative, lastPrice, volume, high, low],
+// with the symbol in front for the rows of the tickers endpoint.
+func (t *Ticker) UnmarshalJSON(data []byte) error {
+	var row []rawJSON
+	if err := json.Unmarshal(data, &row); err != nil {
+		return fmt.Errorf("bad ticker: %w", err)
+	}
+	if len(row) == 11 {
+		if err := json.Unmarshal(row[0], &t.Symbol); err != nil {
+			return fmt.Errorf("bad ticker symbol: %w", err)
+		}
+		row = row[1:]
+	}
+	if len(row) != 10 {
+		return fmt.Errorf("ticker with %d fields, want 10", len(row))
+	}
+	fields := []*float64{&t.Bid, &t.BidSize, &t.Ask, &t.AskSize, &t.DailyChange, &t.DailyChangeRelative, &t.LastPrice, &t.Volume, &t.High, &t.Low}
+	for i, field := range fields {
+		if err := json.Unmarshal(row[i], field); err != nil {
+			return fmt.Errorf("bad ticker field %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// TickerBranch follows the ticker channel of a symbol.
+type TickerBranch struct {
+	symbol    string
+	cancel    context.CancelFunc
+	mux       sync.RWMutex
+	ticker    Ticker
+	ready     bool
+	callbacks []func(Ticker)
+}
+
+// symbol example: ETH:USDT
+func LocalTicker(symbol string, logger *log.Logger) *TickerBranch {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &TickerBranch{
+		symbol: strings.ToUpper(symbol),
+		cancel: cancel,
+	}
+	sub := GetDVFSubscribeMessage("ticker", t.symbol)
+	go runChannelSocket(ctx, t.symbol+" ticker", sub, newBackoff(nil), logger, t.handleFrame)
+	return t
+}
+
+// return the last ticker, ready or not
+func (t *TickerBranch) Get() (Ticker, bool) {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	return t.ticker, t.ready
+}
+
+// OnTicker calls fn on every ticker update, from the socket goroutine.
+func (t *TickerBranch) OnTicker(fn func(Ticker)) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.callbacks = append(t.callbacks, fn)
+}
+
+func (t *TickerBranch) Close() {
+	t.cancel()
+}
+
+func (t *TickerBranch) handleFrame(frame *DVFFrame, received time.Time) error {
+	if frame.Type != "" {
+		return nil
+	}
+	if frame.Snapshot {
+		return errors.New("unexpected ticker list")
+	}
+	var ticker Ticker
+	if err := json.Unmarshal(frame.Payload, &ticker); err != nil {
+		return err
+	}
+	ticker.Symbol = t.symbol
+	ticker.Time = received
+	t.mux.Lock()
+	t.ticker = ticker
+	t.ready = true
+	callbacks := t.callbacks
+	t.mux.Unlock()
+	for _, fn := range callbacks {
+		fn(ticker)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package dvfapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+)
+
+type Trade struct {
+	ID     int64
+	Time   time.Time
+	Price  decimal.Decimal
+	Amount decimal.Decimal
+	// buy or sell, the side of the taker
+	Side string
+}
+
+// TradeEntry is a trade row: [id, mts, amount, price], negative amount for a sell
+type TradeEntry [4]float64
+
+func (e *TradeEntry) UnmarshalJSON(data []byte) error {
+	var row []float64
+	if err := json.Unmarshal(data, &row); err != nil {
+		return fmt.Errorf("bad trade entry: %w", err)
+	}
+	if len(row) != 4 {
+		return fmt.Errorf("trade entry with %d fields, want 4", len(row))
+	}
+	copy(e[:], row)
+	return nil
+}
+
+func (e TradeEntry) Trade() Trade {
+	amount := decimal.NewFromFloat(e[2])
+	side := "buy"
+	if amount.IsNegative() {
+		side = "sell"
+	}
+	return Trade{
+		ID:     int64(e[0]),
+		Time:   time.UnixMilli(int64(e[1])),
+		Price:  decimal.NewFromFloat(e[3]),
+		Amount: amount.Abs(),
+		Side:   side,
+	}
+}
+
+// tradeRing keeps the last trades, oldest first
+type tradeRing struct {
+	trades []Trade
+	next   int
+	full   bool
+}
+
+func newTradeRing(size int) *tradeRing {
+	return &tradeRing{trades: make([]Trade, size)}
+}
+
+func (r *tradeRing) add(trade Trade) {
+	r.trades[r.next] = trade
+	r.next = (r.next + 1) % len(r.trades)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *tradeRing) len() int {
+	if r.full {
+		return len(r.trades)
+	}
+	return r.next
+}
+
+// the last n trades, oldest first
+func (r *tradeRing) last(n int) []Trade {
+	l := r.len()
+	if n <= 0 || n > l {
+		n = l
+	}
+	out := make([]Trade, 0, n)
+	for i := l - n; i < l; i++ {
+		idx := i
+		if r.full {
+			idx = (r.next + i) % len(r.trades)
+		}
+		out = append(out, r.trades[idx])
+	}
+	return out
+}
+
+// seen tells if the trade id is still in the ring
+func (r *tradeRing) seen(id int64) bool {
+	for i := 0; i < r.len(); i++ {
+		if r.trades[i].ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// TradeBranch follows the public trades of a symbol.
+type TradeBranch struct {
+	symbol    string
+	cancel    context.CancelFunc
+	mux       sync.RWMutex
+	ring      *tradeRing
+	callbacks []func(Trade)
+}
+
+// symbol example: ETH:USDT, size is the number of recent trades kept
+func LocalTrades(symbol string, size int, logger *log.Logger) *TradeBranch {
+	if size <= 0 {
+		size = 1000
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &TradeBranch{
+		symbol: strings.ToUpper(symbol),
+		cancel: cancel,
+		ring:   newTradeRing(size),
+	}
+	sub := GetDVFSubscribeMessage("trades", t.symbol)
+	go runChannelSocket(ctx, t.symbol+" trades", sub, newBackoff(nil), logger, t.handleFrame)
+	return t
+}
+
+// OnTrade calls fn for every new trade, from the socket goroutine.
+func (t *TradeBranch) OnTrade(fn func(Trade)) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.callbacks = append(t.callbacks, fn)
+}
+
+// Recent returns the last n trades, oldest first, n <= 0 for all of them.
+func (t *TradeBranch) Recent(n int) []Trade {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	return t.ring.last(n)
+}
+
+func (t *TradeBranch) Close() {
+	t.cancel()
+}
+
+func (t *TradeBranch) handleFrame(frame *DVFFrame, received time.Time) error {
+	var entries []TradeEntry
+	switch {
+	case frame.Type == "" && frame.Snapshot:
+		if err := json.Unmarshal(frame.Payload, &entries); err != nil {
+			return err
+		}
+		// the snapshot comes newest first
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+	case frame.Type == "te" || frame.Type == "tu":
+		var entry TradeEntry
+		if err := json.Unmarshal(frame.Payload, &entry); err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	default:
+		return nil
+	}
+	t.mux.Lock()
+	var fresh []Trade
+	for _, entry := range entries {
+		trade := entry.Trade()
+		// tu repeats the te of the same trade id once it is settled, keep the first one only
+		if t.ring.seen(trade.ID) {
+			continue
+		}
+		t.ring.add(trade)
+		fresh = append(fresh, trade)
+	}
+	callbacks := t.callbacks
+	t.mux.Unlock()
+	if frame.Snapshot {
+		return nil
+	}
+	for _, trade := range fresh {
+		for _, fn := range callbacks {
+			fn(trade)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package dvfapi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Watchdog is a dead-man's switch, once armed it cancels every open order of the account
+// if the strategy stops calling Heartbeat or one of the watched order books goes stale.
+// Cancel-all is retried until it succeeds or the watchdog is disarmed, OnTrigger gets every attempt.
+type Watchdog struct {
+	client    *Client
+	logger    *log.Logger
+	timeout   time.Duration
+	OnTrigger func(report WatchdogReport)
+
+	mux      sync.Mutex
+	lastBeat time.Time
+	armedAt  time.Time
+	books    []watchedBook
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// wait between cancel-all attempts of a triggered watchdog
+const watchdogRetry = 2 * time.Second
+
+type watchedBook struct {
+	book       *OrderBookBranch
+	name       string
+	staleAfter time.Duration
+}
+
+type WatchdogReport struct {
+	Reason   string
+	Time     time.Time
+	Canceled []string
+	Failed   map[string]error
+	Err      error
+}
+
+// timeout is the longest the strategy may go without calling Heartbeat.
+func NewWatchdog(client *Client, timeout time.Duration, logger *log.Logger) *Watchdog {
+	return &Watchdog{
+		client:  client,
+		logger:  logger,
+		timeout: timeout,
+	}
+}
+
+// WatchBook adds an order book feed that must not go longer than staleAfter without updates.
+func (w *Watchdog) WatchBook(name string, book *OrderBookBranch, staleAfter time.Duration) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.books = append(w.books, watchedBook{book: book, name: name, staleAfter: staleAfter})
+}
+
+func (w *Watchdog) Heartbeat() {
+	w.mux.Lock()
+	w.lastBeat = time.Now()
+	w.mux.Unlock()
+}
+
+func (w *Watchdog) Armed() bool {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.cancel != nil
+}
+
+// Arm starts watching, it counts as a heartbeat.
+func (w *Watchdog) Arm() {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.ctx = ctx
+	w.cancel = cancel
+	w.lastBeat = time.Now()
+	w.armedAt = w.lastBeat
+	go w.watch(ctx)
+	w.logger.Infof("watchdog armed, timeout %s", w.timeout)
+}
+
+func (w *Watchdog) Disarm() {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	w.cancel = nil
+	w.logger.Infoln("watchdog disarmed")
+}
+
+func (w *Watchdog) watch(ctx context.Context) {
+	check := w.timeout / 4
+	if check < 100*time.Millisecond {
+		check = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(check)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reason := w.expired()
+			if reason == "" {
+				continue
+			}
+			for !w.trigger(reason) {
+				w.logger.Warningf("watchdog retrying cancel-all in %s", watchdogRetry)
+				if !sleepContext(ctx, watchdogRetry) {
+					return
+				}
+			}
+			// fire once, the strategy has to arm it again
+			w.disarmed(ctx)
+			return
+		}
+	}
+}
+
+// disarm after a trigger, unless the strategy already disarmed and armed again
+func (w *Watchdog) disarmed(ctx context.Context) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.ctx != ctx {
+		return
+	}
+	w.cancel()
+	w.cancel = nil
+	w.logger.Infoln("watchdog disarmed after trigger")
+}
+
+// returns why the switch should fire, empty if everything is fine
+func (w *Watchdog) expired() string {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	now := time.Now()
+	if since := now.Sub(w.lastBeat); since > w.timeout {
+		return fmt.Sprintf("no heartbeat for %s", since.Round(time.Millisecond))
+	}
+	for _, item := range w.books {
+		last := item.book.LastUpdate()
+		if last.IsZero() || last.Before(w.armedAt) {
+			// a feed that never updated since arm counts from the arm
+			last = w.armedAt
+		}
+		if since := now.Sub(last); since > item.staleAfter {
+			return fmt.Sprintf("%s order book stale for %s", item.name, since.Round(time.Millisecond))
+		}
+	}
+	return ""
+}
+
+// returns true once every open order is canceled
+func (w *Watchdog) trigger(reason string) bool {
+	report := WatchdogReport{
+		Reason: reason,
+		Time:   time.Now(),
+		Failed: make(map[string]error),
+	}
+	w.logger.Warningf("watchdog triggered: %s, canceling all orders", reason)
+	results, err := w.client.CancelAllOrders()
+	if err != nil {
+		report.Err = err
+		w.logger.Errorf("watchdog fail to list open orders: %s", err.Error())
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			report.Failed[res.OrderId] = res.Err
+			continue
+		}
+		report.Canceled = append(report.Canceled, res.OrderId)
+	}
+	w.logger.Warningf("watchdog report: %d orders canceled, %d failed", len(report.Canceled), len(report.Failed))
+	for id, err := range report.Failed {
+		w.logger.Errorf("watchdog fail to cancel order %s: %s", id, err.Error())
+	}
+	if w.OnTrigger != nil {
+		w.OnTrigger(report)
+	}
+	return report.Err == nil && len(report.Failed) == 0
+}